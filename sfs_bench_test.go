@@ -0,0 +1,313 @@
+package sfs
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// spinPacket 是一个真实的压缩 spin 响应数据包（base64）
+const spinPacket = "oAfheJx1V2tsXEcVnmvvrtdbJ+tXiN2kaVpFimke8iMlToW7s47t2JIfwXaaRIlEru+dXY98997tfbheQLykSlSq0kggUigSrQIKEih/+IH5gRra0BBUflEa9Q8iVCoKrVChiWtIUzgzZ85GuOVK9njmzpzzne+c8811O2tkVrWdNejfaZYr2xVxOPBLsqwWW5xAlErSkcKPo3bWyRr6+vJsc+HOpc4v3ThyiTc/vWP1C+8+zbvUrPPLvOfrTD380K+qF67nhvjk99TzfX7mjtr4Hk/+iPOzf38dnt/zn7ycG4Kd/OrAA+oAf7d1Hcw9WNxy93fKTvHoV49riw19/Xm2qXD3ledO99x+lW+6jsceHNAP7+u5DW8+y0fSCOdkpqQeXjVun6mdVjv4jy/qjfw3F/dq9+Ru66W3YONsce7x1svobiDPWjgzZttMFLuOqLDH+eBVHI+O6LB5eQjdflMoXD/iL55FNn59/g3N0l+Tbdr8lrvXlEHl5uXTPWvg5kCe3cctY36LcbdHB5fjw+cxylNvY5RPDWn//Lsd6Gb1zZP63I3zb6hoim1mvEfao3mW442nMehuQ1q/MTt1EEd5DnP2zDl0e+nUpCbnzStfUQeL2d4rwPpacbz3yvMQJZj9XJ4187RJ/U6D+rFnMaUnTsDqjlVeO4duf3gL59cmuxTX/KPkBQDyZPHxz99WRQLmDuZZlmeHsGJ2GW4P/w3RiJsY9LOv4PwXH3KN9j1jprdht0nZYJ418ftMYe75OXI286dV7TUxZi7sxfXraKa4PfkBw+OH8izDW00wAyUsIKKeuLn82s80xan1E/qYBd3QVXgfTxXef11TUPgHJrTwT7RS+AAzUEAmZgu3dahPFtZW9UJh3Zz/F5Y3dVe93KkOqVAoo5QC4o6Cpyg6zL4u855S1WPmgzgyC5prax09ob6lI2SFNW20s7COPV64g01X+NhwZJlyJVQZ44W0gZq1bUOZUz0SKso8pY5yMNSN58Y51ukJM4a9CNCCXv1MnXNC/SGiKvzbcPwfIxUNxmraeM8ZLvJm3mFQkqKRxFDvU3NSF1HZU71SwVHlLJnxGz6ifsk3qKH1t9S5Jo4NWs4MypRB12y8t+Jh3mlQ3q+o61njD5mR9JcEkZRqeEPPU5NSd1F7UJ1Tm1zFNmEWKElnvY6pEohTQpl7DmW2zUTRZbjaeQEFa/d3UC8JHd0SJNukp6f+gnVPykRSQhpwYe9+HdXl136q+onffKRBnWMWCFNHvcvubMg4cUjounEff9hwtsd026MRnisalHSH0aVCak9yTJyR0JFCkcTUtQJ0rr3e+x9vqEPKLHFGqPZNYn0dXEfrI2dwfXY7rtPNSlcd3UF0SZCak/ySbm5PXlDhMQtks42UqN7L1BVUZ5RB4ojQjH4buZo/o6Pi7hnkjO55unhfPPuOjpauLLhbNKqPUH7rKm6BCreSHtYVhXqTqp3qiDJFnBAKcRH3r5ivC/raoOuf7uUbONZvuPqV1N+bZ2nesUGAqJWpOW4+0qhGuDtge57Uu65/pCTUm1T1VFeUQeKM0J77GnJG30b0sUJfEXDNq/31+7jdFSU78eIp6YuoWAkSP04BG40s5QSuUH/+lmVLoRDDIo7aYJpKIhGqj71sJOJY+uVIfeqlK0kkHYuxdAQmXMtiTQu2Z/uOaOIXq1idGXvZju0wy5rt5X19vfurfplllLUJN8ua+nv7+/sO9bKsWvHhOzLLcq6oBF88cGhgcJBlHPNZuZttlVExiQMANCbsOAnFqG8veEI5zVTsFVhv4ifRJWtZEPHRUDgykoGvomlgnTI6nISh8J3aNLgZl64rfEDeIqPJoCz9eVkRIczbo4rteXMOxO4ft0MfQoXVzXDaC5ylJ8AiOIWVbTKaWwyemgISZdWTIhxdqULkdgwe4XW2ZEexIk9l+Zfmnrlmxj+Y2/AtM/+zuX/eNuvvGIW/ad5/YFTJRFfv+11mPGDGCTMSDftlNC2EOx8opDO+jnMqcG1vOohnRTnx7Fi4w7Wi5wL3ogawmzGq2bgKk44I/jxq12LF84w/B1mMYRkScQQYnLaXZVmHey8RO2SkYh6XURyEtVHfHcZqoB2MdduYw+mksiDCmdKsqptoEg60sIyuQMZy8NMNP64uQ2bdYjnbieWyOKLrI11RRcs2VyE1E34swmXbm4rSGPJjz7OsY/KcZY3H5kbYrqAqQhsAjQcVMZzEceCPhYEfA7yio/DP16pgNutjQIJ1uzKCXNaEqwrusB0tziSxrg/jBBDu/J9INTWfiLVzwXaW5gPl9tMdbZO+DkzGNWV+LAhHZAQV7wsnJleQBij8evhjgQOdoqw/jKmahDrTJAKGWBzzY+lNixVcgl33A07E9YTtSXdDvjJApWocqlCV2zHs+hFRDSIZ34umS0K0rjCvown/GHTslPATVTaLAaAadcvQ91kDu8t2HKUrhqJPZwC6cjbxAHZJ+jJaFK6KUTkDYDqEkSTUmCf8Kel5MrpHy9ZgGdLqef8vRztYVv1fqkqrlTWzlCsdwTLQqv5SwFLlwPZYelHu8wIqqNSS8AO2CSZyXxgAqhgANi0GMRwRWti8IPBZTkZT9spx6RcrADRvhBQoAYIT0UTNzXIgSooi2NnEX/0WgnoAk6ZPK3I+kS/LybIUIIiZZTeyTTBvsP4LlOWd+Q=="
+
+// spinObject 返回 spinPacket 解码后的对象，作为基准测试的负载
+func spinObject(tb testing.TB) SFSObject {
+	packet, err := base64.StdEncoding.DecodeString(spinPacket)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	v, err := NewUnpacker(packet).Unpack()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return v.(SFSObject)
+}
+
+// 以下是重写前基于 bytes.Buffer 和 binary.Write/binary.Read 的编解码方式，
+// 只保留基准测试负载用到的部分，作为 *Baseline 基准的对照。
+
+// baselinePack 每次新建缓冲区和 zlib.Writer，与重写前的 Packer 相同
+func baselinePack(obj SFSObject, compress bool) ([]byte, error) {
+	body := new(bytes.Buffer)
+	if err := baselineEncode(body, obj); err != nil {
+		return nil, err
+	}
+	data := body.Bytes()
+	var firstByte byte
+	if compress {
+		var compressed bytes.Buffer
+		w := zlib.NewWriter(&compressed)
+		w.Write(data)
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		data = compressed.Bytes()
+		firstByte |= 32
+	}
+	if len(data) > math.MaxUint16 {
+		return nil, errors.New("baseline: packet too large")
+	}
+	out := new(bytes.Buffer)
+	out.WriteByte(firstByte)
+	binary.Write(out, binary.BigEndian, uint16(len(data)))
+	out.Write(data)
+	return out.Bytes(), nil
+}
+
+func baselineEncode(buf *bytes.Buffer, value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		return buf.WriteByte(byte(NULL))
+	case bool:
+		buf.WriteByte(byte(BOOL))
+		return binary.Write(buf, binary.BigEndian, v)
+	case string:
+		buf.WriteByte(byte(UTF_STRING))
+		binary.Write(buf, binary.BigEndian, uint16(len(v)))
+		_, err := buf.WriteString(v)
+		return err
+	case []byte:
+		buf.WriteByte(byte(BYTE_ARRAY))
+		binary.Write(buf, binary.BigEndian, uint32(len(v)))
+		_, err := buf.Write(v)
+		return err
+	case []string:
+		buf.WriteByte(byte(UTF_STRING_ARRAY))
+		binary.Write(buf, binary.BigEndian, uint16(len(v)))
+		for _, s := range v {
+			binary.Write(buf, binary.BigEndian, uint16(len(s)))
+			buf.WriteString(s)
+		}
+		return nil
+	case SFSObject:
+		buf.WriteByte(byte(SFS_OBJECT))
+		binary.Write(buf, binary.BigEndian, uint16(len(v)))
+		for key, val := range v {
+			binary.Write(buf, binary.BigEndian, uint16(len(key)))
+			buf.WriteString(key)
+			if err := baselineEncode(buf, val); err != nil {
+				return err
+			}
+		}
+		return nil
+	case SFSArray:
+		buf.WriteByte(byte(SFS_ARRAY))
+		binary.Write(buf, binary.BigEndian, uint16(len(v)))
+		for _, val := range v {
+			if err := baselineEncode(buf, val); err != nil {
+				return err
+			}
+		}
+		return nil
+	case byte, int16, int32, int64, float32, float64:
+		buf.WriteByte(byte(wireTypeOf(v)))
+		return binary.Write(buf, binary.BigEndian, v)
+	case []bool, []int16, []int32, []int64, []float32, []float64:
+		buf.WriteByte(byte(wireTypeOf(v)))
+		binary.Write(buf, binary.BigEndian, uint16(reflect.ValueOf(v).Len()))
+		return binary.Write(buf, binary.BigEndian, v)
+	default:
+		return ErrUnsupportedType
+	}
+}
+
+// baselineUnpack 用 binary.Read 逐个读取，压缩数据用新建的 zlib.Reader 解压
+func baselineUnpack(packet []byte) (interface{}, error) {
+	if len(packet) < 3 || packet[0]&8 != 0 {
+		return nil, errors.New("baseline: unsupported packet header")
+	}
+	data := packet[3:]
+	if packet[0]&32 != 0 {
+		r, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		var decompressed bytes.Buffer
+		if _, err := io.Copy(&decompressed, r); err != nil {
+			return nil, err
+		}
+		data = decompressed.Bytes()
+	}
+	return baselineDecode(bytes.NewBuffer(data))
+}
+
+// baselineFixed 是可以直接用 binary.Read 读取的类型，数组先读 2 字节的元素数量
+var baselineFixed = map[DataType]reflect.Type{
+	BYTE:         reflect.TypeOf(byte(0)),
+	SHORT:        reflect.TypeOf(int16(0)),
+	INT:          reflect.TypeOf(int32(0)),
+	LONG:         reflect.TypeOf(int64(0)),
+	FLOAT:        reflect.TypeOf(float32(0)),
+	DOUBLE:       reflect.TypeOf(float64(0)),
+	BOOL_ARRAY:   reflect.TypeOf([]bool(nil)),
+	SHORT_ARRAY:  reflect.TypeOf([]int16(nil)),
+	INT_ARRAY:    reflect.TypeOf([]int32(nil)),
+	LONG_ARRAY:   reflect.TypeOf([]int64(nil)),
+	FLOAT_ARRAY:  reflect.TypeOf([]float32(nil)),
+	DOUBLE_ARRAY: reflect.TypeOf([]float64(nil)),
+}
+
+func baselineDecode(buf *bytes.Buffer) (interface{}, error) {
+	typeByte, err := buf.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	dataType := DataType(typeByte)
+	readString := func() (string, error) {
+		var n uint16
+		if err := binary.Read(buf, binary.BigEndian, &n); err != nil {
+			return "", err
+		}
+		b := make([]byte, n)
+		_, err := io.ReadFull(buf, b)
+		return string(b), err
+	}
+
+	if t, ok := baselineFixed[dataType]; ok {
+		v := reflect.New(t)
+		if t.Kind() == reflect.Slice {
+			var n uint16
+			if err := binary.Read(buf, binary.BigEndian, &n); err != nil {
+				return nil, err
+			}
+			v.Elem().Set(reflect.MakeSlice(t, int(n), int(n)))
+		}
+		err := binary.Read(buf, binary.BigEndian, v.Interface())
+		return v.Elem().Interface(), err
+	}
+
+	switch dataType {
+	case NULL:
+		return nil, nil
+	case BOOL:
+		b, err := buf.ReadByte()
+		return b != 0, err
+	case UTF_STRING:
+		return readString()
+	case BYTE_ARRAY:
+		var n uint32
+		if err := binary.Read(buf, binary.BigEndian, &n); err != nil {
+			return nil, err
+		}
+		b := make([]byte, n)
+		_, err := io.ReadFull(buf, b)
+		return b, err
+	case UTF_STRING_ARRAY:
+		var n uint16
+		if err := binary.Read(buf, binary.BigEndian, &n); err != nil {
+			return nil, err
+		}
+		arr := make([]string, n)
+		for i := range arr {
+			if arr[i], err = readString(); err != nil {
+				return nil, err
+			}
+		}
+		return arr, nil
+	case SFS_OBJECT:
+		var n uint16
+		if err := binary.Read(buf, binary.BigEndian, &n); err != nil {
+			return nil, err
+		}
+		obj := make(SFSObject)
+		for i := uint16(0); i < n; i++ {
+			key, err := readString()
+			if err != nil {
+				return nil, err
+			}
+			if obj[key], err = baselineDecode(buf); err != nil {
+				return nil, err
+			}
+		}
+		return obj, nil
+	case SFS_ARRAY:
+		var n uint16
+		if err := binary.Read(buf, binary.BigEndian, &n); err != nil {
+			return nil, err
+		}
+		arr := make(SFSArray, n)
+		for i := range arr {
+			if arr[i], err = baselineDecode(buf); err != nil {
+				return nil, err
+			}
+		}
+		return arr, nil
+	default:
+		return nil, ErrUnknownType
+	}
+}
+
+// TestBaselineEquivalence 确认基准对照的编解码结果与 Pack/Unpack 一致
+func TestBaselineEquivalence(t *testing.T) {
+	obj := spinObject(t)
+	for _, compress := range []bool{false, true} {
+		data, err := baselinePack(obj, compress)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, err := Unpack(data, UnpackOptions{}); err != nil || !reflect.DeepEqual(v, obj) {
+			t.Fatalf("Unpack of baseline packet differs: %v", err)
+		}
+
+		data, err = Pack(obj, PackOptions{Compress: compress})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, err := baselineUnpack(data); err != nil || !reflect.DeepEqual(v, obj) {
+			t.Fatalf("baseline unpack of Pack output differs: %v", err)
+		}
+	}
+}
+
+func benchmarkPack(b *testing.B, compress, baseline bool) {
+	obj := spinObject(b)
+	p := NewPacker()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var err error
+		if baseline {
+			_, err = baselinePack(obj, compress)
+		} else {
+			_, err = p.Pack(obj, compress)
+		}
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkUnpack(b *testing.B, compress, baseline bool) {
+	data, err := NewPacker().Pack(spinObject(b), compress)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if baseline {
+			_, err = baselineUnpack(data)
+		} else {
+			_, err = NewUnpacker(data).Unpack()
+		}
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPack(b *testing.B)                   { benchmarkPack(b, false, false) }
+func BenchmarkPackBaseline(b *testing.B)           { benchmarkPack(b, false, true) }
+func BenchmarkPackCompressed(b *testing.B)         { benchmarkPack(b, true, false) }
+func BenchmarkPackCompressedBaseline(b *testing.B) { benchmarkPack(b, true, true) }
+
+func BenchmarkUnpack(b *testing.B)                   { benchmarkUnpack(b, false, false) }
+func BenchmarkUnpackBaseline(b *testing.B)           { benchmarkUnpack(b, false, true) }
+func BenchmarkUnpackCompressed(b *testing.B)         { benchmarkUnpack(b, true, false) }
+func BenchmarkUnpackCompressedBaseline(b *testing.B) { benchmarkUnpack(b, true, true) }
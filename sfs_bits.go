@@ -0,0 +1,398 @@
+package sfs
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// bitsInfo 是 tag 中 bits、shape=、bit=N 选项的内容
+type bitsInfo struct {
+	shape []int // shape=5x3 固定的维度；nil 表示维度记录在 BYTE_ARRAY 开头
+	bit   int   // bit=N 的位置，-1 表示 bits 选项
+}
+
+// isGroup 报告是否是 bit=N 字段
+func (b *bitsInfo) isGroup() bool {
+	return b != nil && b.bit >= 0
+}
+
+// parseShape 解析 shape= 的值，例如 "15" 或 "5x3"
+func parseShape(s string) ([]int, error) {
+	dims := strings.Split(s, "x")
+	if len(dims) > 2 {
+		return nil, fmt.Errorf("shape=%s has more than 2 dimensions", s)
+	}
+	shape := make([]int, len(dims))
+	for i, dim := range dims {
+		n, err := strconv.Atoi(dim)
+		if err != nil || n <= 0 || n > maxCount {
+			return nil, fmt.Errorf("invalid shape=%s", s)
+		}
+		shape[i] = n
+	}
+	return shape, nil
+}
+
+// bitWidth 返回整数数据类型的位数，其他类型返回 0
+func bitWidth(dtype DataType) int {
+	switch dtype {
+	case BYTE:
+		return 8
+	case SHORT:
+		return 16
+	case INT:
+		return 32
+	case LONG:
+		return 64
+	default:
+		return 0
+	}
+}
+
+// boolDims 返回 []bool（1）或 [][]bool（2）的维数，切片和数组都可以，其他类型返回 0
+func boolDims(t reflect.Type) int {
+	dims := 0
+	for t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+		dims++
+	}
+	if t.Kind() != reflect.Bool || dims > 2 {
+		return 0
+	}
+	return dims
+}
+
+// checkBitsType 检查 bits 字段的 Go 类型、shape= 和数据类型
+func checkBitsType(info fieldInfo, t reflect.Type) error {
+	dims := boolDims(t)
+	if dims == 0 {
+		return fmt.Errorf("bits cannot be used with %s", t)
+	}
+	shape := info.bits.shape
+	if shape != nil && len(shape) != dims {
+		return fmt.Errorf("shape has %d dimensions, %s has %d", len(shape), t, dims)
+	}
+
+	switch info.dataType {
+	case NULL, BYTE_ARRAY:
+		return nil
+	case BYTE, SHORT, INT, LONG:
+		if shape == nil {
+			return fmt.Errorf("bits stored as %s requires shape=", info.dataType)
+		}
+		if n := shapeSize(shape); n > bitWidth(info.dataType) {
+			return fmt.Errorf("shape has %d cells, %s holds %d bits", n, info.dataType, bitWidth(info.dataType))
+		}
+		return nil
+	}
+	return fmt.Errorf("bits cannot be stored as %s", info.dataType)
+}
+
+func shapeSize(shape []int) int {
+	n := 1
+	for _, dim := range shape {
+		n *= dim
+	}
+	return n
+}
+
+// encodeBits 将 []bool 或 [][]bool 按行优先、每字节从低位开始打包为位图。
+// 没有 shape= 时 BYTE_ARRAY 开头记录维度：[]bool 为 2 字节长度，
+// [][]bool 为 2 字节行数加上每行 2 字节的长度，各行可以不等长。
+func encodeBits(val reflect.Value, info *bitsInfo, dtype DataType) (interface{}, error) {
+	if val.Kind() == reflect.Slice && val.IsNil() {
+		return nil, nil
+	}
+
+	var cells []bool
+	var header []byte
+	var dims []int
+	if val.Type().Elem().Kind() == reflect.Bool {
+		dims = []int{val.Len()}
+		cells = appendBools(cells, val)
+	} else {
+		dims = []int{val.Len(), -1}
+		header = make([]byte, 2+2*val.Len())
+		for i := 0; i < val.Len(); i++ {
+			row := val.Index(i)
+			if row.Len() > maxCount {
+				return nil, fmt.Errorf("%w: row %d has %d elements", ErrLimitExceeded, i, row.Len())
+			}
+			if i == 0 {
+				dims[1] = row.Len()
+			} else if row.Len() != dims[1] {
+				dims[1] = -1 // 不等长
+			}
+			binary.BigEndian.PutUint16(header[2+2*i:], uint16(row.Len()))
+			cells = appendBools(cells, row)
+		}
+	}
+	if dims[0] > maxCount {
+		return nil, fmt.Errorf("%w: %d elements", ErrLimitExceeded, dims[0])
+	}
+
+	if info.shape != nil {
+		for i, dim := range info.shape {
+			if dims[i] != dim {
+				return nil, fmt.Errorf("value does not match shape=%s", formatShape(info.shape))
+			}
+		}
+		header = nil
+	} else if header == nil {
+		header = make([]byte, 2)
+	}
+	if header != nil {
+		binary.BigEndian.PutUint16(header, uint16(dims[0]))
+	}
+
+	if width := bitWidth(dtype); width > 0 {
+		var n uint64
+		for i, c := range cells {
+			if c {
+				n |= 1 << i
+			}
+		}
+		switch dtype {
+		case BYTE:
+			return byte(n), nil
+		case SHORT:
+			return int16(n), nil
+		case INT:
+			return int32(n), nil
+		default:
+			return int64(n), nil
+		}
+	}
+
+	out := make([]byte, len(header), len(header)+(len(cells)+7)/8)
+	copy(out, header)
+	return appendBitmap(out, cells), nil
+}
+
+func appendBools(cells []bool, val reflect.Value) []bool {
+	for i := 0; i < val.Len(); i++ {
+		cells = append(cells, val.Index(i).Bool())
+	}
+	return cells
+}
+
+func appendBitmap(b []byte, cells []bool) []byte {
+	for i := 0; i < len(cells); i += 8 {
+		var c byte
+		for j := 0; j < 8 && i+j < len(cells); j++ {
+			if cells[i+j] {
+				c |= 1 << j
+			}
+		}
+		b = append(b, c)
+	}
+	return b
+}
+
+func formatShape(shape []int) string {
+	dims := make([]string, len(shape))
+	for i, dim := range shape {
+		dims[i] = strconv.Itoa(dim)
+	}
+	return strings.Join(dims, "x")
+}
+
+// decodeBits 是 encodeBits 的逆过程
+func decodeBits(field reflect.Value, sfsValue interface{}, info *bitsInfo) error {
+	if sfsValue == nil {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+
+	var bitmap []byte
+	switch v := sfsValue.(type) {
+	case []byte:
+		bitmap = v
+	case byte:
+		bitmap = []byte{v}
+	case int16:
+		bitmap = binary.LittleEndian.AppendUint16(nil, uint16(v))
+	case int32:
+		bitmap = binary.LittleEndian.AppendUint32(nil, uint32(v))
+	case int64:
+		bitmap = binary.LittleEndian.AppendUint64(nil, uint64(v))
+	default:
+		return fmt.Errorf("cannot convert %T to bits", sfsValue)
+	}
+
+	// 每行的长度
+	var rows []int
+	twoDim := field.Type().Elem().Kind() != reflect.Bool
+	switch {
+	case info.shape != nil:
+		if _, ok := sfsValue.([]byte); ok && len(bitmap) != (shapeSize(info.shape)+7)/8 {
+			return fmt.Errorf("bitmap has %d bytes, shape=%s needs %d", len(bitmap), formatShape(info.shape), (shapeSize(info.shape)+7)/8)
+		}
+		rows = []int{info.shape[0]}
+		if twoDim {
+			rows = make([]int, info.shape[0])
+			for i := range rows {
+				rows[i] = info.shape[1]
+			}
+		}
+	case len(bitmap) < 2:
+		return fmt.Errorf("%w: bits header", ErrTruncated)
+	default:
+		n := int(binary.BigEndian.Uint16(bitmap))
+		bitmap = bitmap[2:]
+		rows = []int{n}
+		if twoDim {
+			if len(bitmap) < 2*n {
+				return fmt.Errorf("%w: bits header", ErrTruncated)
+			}
+			rows = make([]int, n)
+			for i := range rows {
+				rows[i] = int(binary.BigEndian.Uint16(bitmap[2*i:]))
+			}
+			bitmap = bitmap[2*n:]
+		}
+	}
+
+	total := 0
+	for _, n := range rows {
+		total += n
+	}
+	if len(bitmap)*8 < total {
+		return fmt.Errorf("%w: bitmap has %d bits, need %d", ErrTruncated, len(bitmap)*8, total)
+	}
+
+	pos := 0
+	fill := func(dst reflect.Value, n int) error {
+		if err := setLen(dst, n); err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			dst.Index(i).SetBool(bitmap[pos/8]&(1<<(pos%8)) != 0)
+			pos++
+		}
+		return nil
+	}
+	if !twoDim {
+		return fill(field, rows[0])
+	}
+	if err := setLen(field, len(rows)); err != nil {
+		return err
+	}
+	for i, n := range rows {
+		if err := fill(field.Index(i), n); err != nil {
+			return prefixPath(err, indexSeg(i), field.Index(i).Type(), NULL)
+		}
+	}
+	return nil
+}
+
+// setLen 为切片分配 n 个元素，数组的长度必须是 n
+func setLen(v reflect.Value, n int) error {
+	if v.Kind() == reflect.Array {
+		if v.Len() != n {
+			return fmt.Errorf("cannot store %d elements in %s", n, v.Type())
+		}
+		return nil
+	}
+	v.Set(reflect.MakeSlice(v.Type(), n, n))
+	return nil
+}
+
+// bitGroup 是共用一个 key 的 bit=N 字段，打包为一个 BYTE/SHORT/INT/LONG
+type bitGroup struct {
+	indexes []int // 字段下标
+	bits    []int // 对应的位
+	dtype   DataType
+}
+
+// add 加入一个 bit=N 字段，检查位置冲突和 type= 是否一致
+func (g *bitGroup) add(info fieldInfo, index int, t reflect.Type) error {
+	if t.Kind() != reflect.Bool {
+		return fmt.Errorf("bit=%d cannot be used with %s", info.bits.bit, t)
+	}
+	for _, bit := range g.bits {
+		if bit == info.bits.bit {
+			return fmt.Errorf("bit %d of %q is already used", bit, info.name)
+		}
+	}
+	if info.dataType != NULL {
+		if bitWidth(info.dataType) == 0 {
+			return fmt.Errorf("bit=N cannot be stored as %s", info.dataType)
+		}
+		if g.dtype != NULL && g.dtype != info.dataType {
+			return fmt.Errorf("type=%s conflicts with type=%s of the same bit group", info.dataType, g.dtype)
+		}
+		g.dtype = info.dataType
+	}
+	g.indexes = append(g.indexes, index)
+	g.bits = append(g.bits, info.bits.bit)
+	return nil
+}
+
+// finish 确定数据类型：没有 type= 时使用能容纳最高位的最小整数类型
+func (g *bitGroup) finish() error {
+	high := 0
+	for _, bit := range g.bits {
+		high = max(high, bit)
+	}
+	if g.dtype == NULL {
+		for _, dtype := range []DataType{BYTE, SHORT, INT, LONG} {
+			if high < bitWidth(dtype) {
+				g.dtype = dtype
+				break
+			}
+		}
+	}
+	if high >= bitWidth(g.dtype) {
+		return fmt.Errorf("bit %d does not fit in %s", high, g.dtype)
+	}
+	return nil
+}
+
+func (g *bitGroup) value(val reflect.Value) uint64 {
+	var n uint64
+	for i, index := range g.indexes {
+		if val.Field(index).Bool() {
+			n |= 1 << g.bits[i]
+		}
+	}
+	return n
+}
+
+func (g *bitGroup) encode(val reflect.Value) (interface{}, error) {
+	n := g.value(val)
+	switch g.dtype {
+	case BYTE:
+		return byte(n), nil
+	case SHORT:
+		return int16(n), nil
+	case INT:
+		return int32(n), nil
+	default:
+		return int64(n), nil
+	}
+}
+
+func (g *bitGroup) decode(val reflect.Value, sfsValue interface{}) error {
+	var n uint64
+	switch v := sfsValue.(type) {
+	case nil:
+	case byte:
+		n = uint64(v)
+	case int16:
+		n = uint64(uint16(v))
+	case int32:
+		n = uint64(uint32(v))
+	case int64:
+		n = uint64(v)
+	default:
+		return fmt.Errorf("cannot convert %T to bit flags", sfsValue)
+	}
+	for i, index := range g.indexes {
+		val.Field(index).SetBool(n&(1<<g.bits[i]) != 0)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package sfs
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type lightFlags struct {
+	ScreenHitData [][]bool `sfs:"screenHitData,bits"`
+	SetLightFlag  [][]bool `sfs:"setLightFlag,bits,shape=5x3,type=INT"`
+	Lines         []bool   `sfs:"lines,bits,optional"`
+	Reels         [5]bool  `sfs:"reels,bits,shape=5"`
+
+	FreeSpin bool `sfs:"flags,bit=0"`
+	Respin   bool `sfs:"flags,bit=1"`
+	Bonus    bool `sfs:"flags,bit=9,optional"`
+}
+
+func TestBits(t *testing.T) {
+	in := lightFlags{
+		ScreenHitData: [][]bool{{true, false, true}, {false}, {true, true, true, true}},
+		SetLightFlag: [][]bool{
+			{true, false, false}, {false, true, false}, {false, false, true},
+			{false, false, false}, {true, true, true},
+		},
+		Lines:    []bool{false, true, false, false, false, false, false, false, true},
+		Reels:    [5]bool{false, false, true, false, false},
+		FreeSpin: true,
+		Bonus:    true,
+	}
+	obj, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := SFSObject{
+		// 3 行，每行长度 3、1、4，然后是 8 个位
+		"screenHitData": []byte{0, 3, 0, 3, 0, 1, 0, 4, 0xF5},
+		"setLightFlag":  int32(0x7111),
+		"lines":         []byte{0, 9, 0x02, 0x01},
+		"reels":         []byte{0x04},
+		"flags":         int16(0x201),
+	}
+	if !reflect.DeepEqual(obj, want) {
+		t.Fatalf("Marshal = %v, want %v", obj, want)
+	}
+
+	// 打包后再解包，检查形状不变
+	var out lightFlags
+	roundTrip(t, in, &out)
+
+	// optional：空切片和全为 false 的字段组被省略
+	obj, err = Marshal(lightFlags{SetLightFlag: in.SetLightFlag})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := obj["lines"]; ok {
+		t.Error("empty optional bits should be omitted")
+	}
+	if _, ok := obj["flags"]; ok {
+		t.Error("optional bit group with no bits set should be omitted")
+	}
+
+	// 行数或某一行的长度与 shape= 不符
+	for _, bad := range [][][]bool{
+		{{true}},
+		{{true, false, true}, {true}, {false, false, false}, {true, true, true}, {false, true, false}},
+	} {
+		_, err := Marshal(lightFlags{SetLightFlag: bad})
+		if err == nil || !strings.Contains(err.Error(), "setLightFlag") || !strings.Contains(err.Error(), "shape") {
+			t.Errorf("%v: expected shape mismatch at setLightFlag, got %v", bad, err)
+		}
+	}
+	if err := Unmarshal(SFSObject{"screenHitData": []byte{0, 2, 0, 9}}, &out); err == nil {
+		t.Error("expected error for truncated bits")
+	}
+
+	type badBits struct {
+		A []int32  `sfs:"a,bits"`
+		B [][]bool `sfs:"b,bits,shape=5"`
+		C []bool   `sfs:"c,bits,type=INT"`
+		D []bool   `sfs:"d,bits,shape=40,type=INT"`
+		E int32    `sfs:"e,bit=0"`
+		F bool     `sfs:"f,bit=3"`
+		G bool     `sfs:"f,bit=3"`
+		H bool     `sfs:"h,bit=8,type=BYTE"`
+		I bool     `sfs:"i,bit=1,bits"`
+	}
+	err = Precompile(badBits{})
+	for _, field := range []string{".A:", ".B:", ".C:", ".D:", ".E:", ".G:", `"h"`, ".I:"} {
+		if err == nil || !strings.Contains(err.Error(), field) {
+			t.Errorf("expected error for %s, got %v", field, err)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package sfs
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// coerce 在 Coerce 模式下尝试数值类型之间的宽松转换。
+// 返回的 bool 表示该组合是否由 coerce 处理，为 false 时调用方继续走严格模式的错误。
+func (d *decoder) coerce(field reflect.Value, sfsValue interface{}) (bool, error) {
+	k := field.Kind()
+	if !isIntKind(k) && !isUintKind(k) && k != reflect.Float32 && k != reflect.Float64 {
+		return false, nil
+	}
+
+	n, ok := numericValue(sfsValue)
+	if !ok {
+		return false, nil
+	}
+	return true, n.setTo(field)
+}
+
+// coerceSlice 将数值数组逐个元素转换为目标切片的元素类型
+func (d *decoder) coerceSlice(field reflect.Value, sfsValue interface{}) (bool, error) {
+	src := reflect.ValueOf(sfsValue)
+	if src.Kind() != reflect.Slice || (field.Kind() != reflect.Slice && field.Kind() != reflect.Array) {
+		return false, nil
+	}
+
+	elemType := field.Type().Elem()
+	k := elemType.Kind()
+	if !isIntKind(k) && !isUintKind(k) && k != reflect.Float32 && k != reflect.Float64 {
+		return false, nil
+	}
+
+	slice, err := makeSeq(field.Type(), src.Len())
+	if err != nil {
+		return true, err
+	}
+	for i := 0; i < src.Len(); i++ {
+		v := src.Index(i).Interface()
+		n, ok := numericValue(v)
+		if !ok {
+			return false, nil
+		}
+		if err := n.setTo(slice.Index(i)); err != nil {
+			return true, prefixPath(err, indexSeg(i), elemType, wireTypeOf(v))
+		}
+	}
+	field.Set(slice)
+	return true, nil
+}
+
+// number 是宽松模式下的中间数值表示，isInt 为 true 时以 i 为准，否则以 f 为准
+type number struct {
+	i     int64
+	f     float64
+	isInt bool
+}
+
+func numericValue(v interface{}) (number, bool) {
+	switch n := v.(type) {
+	case byte:
+		return number{i: int64(n), isInt: true}, true
+	case int16:
+		return number{i: int64(n), isInt: true}, true
+	case int32:
+		return number{i: int64(n), isInt: true}, true
+	case int64:
+		return number{i: n, isInt: true}, true
+	case int:
+		return number{i: int64(n), isInt: true}, true
+	case float32:
+		return number{f: float64(n)}, true
+	case float64:
+		return number{f: n}, true
+	case string:
+		s := strings.TrimSpace(n)
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return number{i: i, isInt: true}, true
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return number{f: f}, true
+		}
+	}
+	return number{}, false
+}
+
+func (n number) String() string {
+	if n.isInt {
+		return strconv.FormatInt(n.i, 10)
+	}
+	return strconv.FormatFloat(n.f, 'g', -1, 64)
+}
+
+// setTo 将数值写入整数或浮点字段，超出范围、丢失小数部分或整数无法精确表示为浮点数时返回错误
+func (n number) setTo(field reflect.Value) error {
+	switch k := field.Kind(); {
+	case isIntKind(k) || isUintKind(k):
+		i := n.i
+		if !n.isInt {
+			if math.IsNaN(n.f) || math.IsInf(n.f, 0) || n.f != math.Trunc(n.f) {
+				return fmt.Errorf("value %s is not an integer", n)
+			}
+			if n.f < math.MinInt64 || n.f >= math.MaxInt64 {
+				return fmt.Errorf("value %s overflows %s", n, field.Type())
+			}
+			i = int64(n.f)
+		}
+		return setInt(field, i)
+
+	case k == reflect.Float32 || k == reflect.Float64:
+		f := n.f
+		if n.isInt {
+			// 超过 2^53 的整数不一定能用浮点数精确表示
+			f = float64(n.i)
+			if f >= math.MaxInt64 || int64(f) != n.i || k == reflect.Float32 && float64(float32(f)) != f {
+				return fmt.Errorf("value %s cannot be represented exactly by %s", n, field.Type())
+			}
+		}
+		if field.OverflowFloat(f) {
+			return fmt.Errorf("value %s overflows %s", n, field.Type())
+		}
+		field.SetFloat(f)
+		return nil
+	}
+	return fmt.Errorf("cannot convert number to %s", field.Type())
+}
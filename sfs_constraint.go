@@ -0,0 +1,374 @@
+package sfs
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// constraintOpt 是 tag 中的一个约束选项，例如 min=1 中 name 为 "min"，arg 为 "1"
+type constraintOpt struct {
+	name, arg string
+}
+
+// constraintNames 是支持的约束选项。pattern= 必须是 tag 的最后一个选项，
+// 因为正则表达式中可以有逗号；它之后出现其他选项时 parseTag 报错。
+var constraintNames = []string{"min", "max", "len", "maxlen", "oneof", "pattern"}
+
+// parseConstraint 解析 min=1 形式的约束选项，不是约束选项时 ok 为 false
+func parseConstraint(part string) (opt constraintOpt, ok bool) {
+	name, arg, found := strings.Cut(part, "=")
+	if !found {
+		return opt, false
+	}
+	for _, n := range constraintNames {
+		if n == name {
+			return constraintOpt{name: name, arg: arg}, true
+		}
+	}
+	return opt, false
+}
+
+// check 检查一个字段值，返回的错误包装 ErrConstraint
+type check func(v reflect.Value) error
+
+// compileConstraints 为 Go 类型 t 编译约束选项，选项不适用于 t 或参数不合法时返回错误
+func compileConstraints(opts []constraintOpt, t reflect.Type) ([]check, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	var checks []check
+	for _, opt := range opts {
+		c, err := compileConstraint(opt, t)
+		if err != nil {
+			return nil, fmt.Errorf("%s=%s: %v", opt.name, opt.arg, err)
+		}
+		checks = append(checks, c)
+	}
+	return checks, nil
+}
+
+func compileConstraint(opt constraintOpt, t reflect.Type) (check, error) {
+	fail := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: "+format, append([]interface{}{ErrConstraint}, args...)...)
+	}
+
+	switch opt.name {
+	case "min", "max":
+		cmp, err := compileCompare(opt.arg, t)
+		if err != nil {
+			return nil, err
+		}
+		if opt.name == "min" {
+			return func(v reflect.Value) error {
+				if cmp(v) < 0 {
+					return fail("%v is less than min=%s", v.Interface(), opt.arg)
+				}
+				return nil
+			}, nil
+		}
+		return func(v reflect.Value) error {
+			if cmp(v) > 0 {
+				return fail("%v is greater than max=%s", v.Interface(), opt.arg)
+			}
+			return nil
+		}, nil
+
+	case "len", "maxlen":
+		n, err := strconv.Atoi(opt.arg)
+		if err != nil || n < 0 {
+			return nil, errors.New("invalid length")
+		}
+		switch t.Kind() {
+		case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		default:
+			return nil, fmt.Errorf("cannot be used with %s", t)
+		}
+		if opt.name == "len" {
+			return func(v reflect.Value) error {
+				if l := lengthOf(v); l != n {
+					return fail("length %d is not len=%d", l, n)
+				}
+				return nil
+			}, nil
+		}
+		return func(v reflect.Value) error {
+			if l := lengthOf(v); l > n {
+				return fail("length %d is greater than maxlen=%d", l, n)
+			}
+			return nil
+		}, nil
+
+	case "oneof":
+		allowed := strings.Split(opt.arg, "|")
+		var match func(v reflect.Value) bool
+		switch {
+		case t.Kind() == reflect.String:
+			set := make(map[string]bool, len(allowed))
+			for _, s := range allowed {
+				set[s] = true
+			}
+			match = func(v reflect.Value) bool { return set[v.String()] }
+		case isIntKind(t.Kind()) || isUintKind(t.Kind()):
+			cmps := make([]func(reflect.Value) int, len(allowed))
+			for i, s := range allowed {
+				cmp, err := compileCompare(s, t)
+				if err != nil {
+					return nil, err
+				}
+				cmps[i] = cmp
+			}
+			match = func(v reflect.Value) bool {
+				for _, cmp := range cmps {
+					if cmp(v) == 0 {
+						return true
+					}
+				}
+				return false
+			}
+		default:
+			return nil, fmt.Errorf("cannot be used with %s", t)
+		}
+		return func(v reflect.Value) error {
+			if !match(v) {
+				return fail("%v is not one of %s", v.Interface(), opt.arg)
+			}
+			return nil
+		}, nil
+
+	case "pattern":
+		if t.Kind() != reflect.String {
+			return nil, fmt.Errorf("cannot be used with %s", t)
+		}
+		re, err := regexp.Compile(opt.arg)
+		if err != nil {
+			return nil, err
+		}
+		return func(v reflect.Value) error {
+			if !re.MatchString(v.String()) {
+				return fail("%q does not match pattern=%s", v.String(), opt.arg)
+			}
+			return nil
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown constraint")
+}
+
+// compileCompare 解析 min=/max=/oneof= 的数值参数，返回比较函数（值 < 参数时为 -1）
+func compileCompare(arg string, t reflect.Type) (func(v reflect.Value) int, error) {
+	switch {
+	case t == decimalType:
+		bound, err := ParseDecimal(arg)
+		if err != nil {
+			return nil, err
+		}
+		return func(v reflect.Value) int {
+			return v.Interface().(Decimal).Cmp(bound)
+		}, nil
+	case isIntKind(t.Kind()):
+		bound, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %q", arg)
+		}
+		return func(v reflect.Value) int {
+			return cmpOrdered(v.Int(), bound)
+		}, nil
+	case isUintKind(t.Kind()):
+		bound, err := strconv.ParseUint(arg, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid unsigned integer %q", arg)
+		}
+		return func(v reflect.Value) int {
+			return cmpOrdered(v.Uint(), bound)
+		}, nil
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		bound, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", arg)
+		}
+		return func(v reflect.Value) int {
+			return cmpOrdered(v.Float(), bound)
+		}, nil
+	}
+	return nil, fmt.Errorf("cannot be used with %s", t)
+}
+
+func cmpOrdered[T int64 | uint64 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// lengthOf 返回字符串的字符数，或切片、数组、map 的元素个数
+func lengthOf(v reflect.Value) int {
+	if v.Kind() == reflect.String {
+		return utf8.RuneCountInString(v.String())
+	}
+	return v.Len()
+}
+
+// runChecks 对字段值执行约束检查：nil 指针、未设置的 Optional 和无效的 Nullable 跳过
+func runChecks(checks []check, v reflect.Value) error {
+	for {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return nil
+			}
+			v = v.Elem()
+			continue
+		}
+		if wrapKindOf(v.Type()) != wrapNone {
+			if !v.Field(1).Bool() {
+				return nil
+			}
+			v = v.Field(0)
+			continue
+		}
+		break
+	}
+	for _, c := range checks {
+		if err := c(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeChecked 解码字段后检查约束
+func (fp *fieldPlan) decodeChecked(d *decoder, field reflect.Value, sfsValue interface{}) error {
+	if err := fp.decode(d, field, sfsValue); err != nil {
+		return err
+	}
+	return runChecks(fp.checks, field)
+}
+
+// ValidateStruct 检查结构体（及嵌套的结构体、切片和 map 中的结构体）所有字段的
+// min=、max=、len=、maxlen=、oneof=、pattern= 约束，报告所有违反约束的字段。
+// 每个问题都是带路径的 FieldError，包装 ErrConstraint；多个问题时返回 MultiError。
+// Unmarshal 在解码时已经检查这些约束，ValidateStruct 用于检查其他来源的值。
+func ValidateStruct(v interface{}) error {
+	val := reflect.ValueOf(v)
+	for val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return errors.New("cannot validate nil")
+		}
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return errors.New("only structs can be validated")
+	}
+	errs, err := validateStruct("", val, nil)
+	if err != nil {
+		return err
+	}
+	return joinErrors(errs)
+}
+
+// validateStruct 检查结构体的字段，将违反约束的字段追加到 errs；tag 错误时返回 err
+func validateStruct(path string, val reflect.Value, errs []error) ([]error, error) {
+	plan, err := cachedPlan(val.Type())
+	if err != nil {
+		return nil, err
+	}
+	for i := range plan.fields {
+		fp := &plan.fields[i]
+		field := fp.value(val)
+		seg := fp.name
+		if plan.tuple {
+			seg = indexSeg(i)
+		}
+		fieldPath := joinPath(path, seg)
+		// 与 Unmarshal 一致，Marshal 时省略的 optional 字段不检查约束
+		if fp.omit(field) {
+			continue
+		}
+		if err := runChecks(fp.checks, field); err != nil {
+			errs = append(errs, &FieldError{Path: fieldPath, GoType: field.Type(), WireType: fp.dataType, Err: err})
+		}
+		if fp.group == nil {
+			if errs, err = validateNested(fieldPath, field, errs); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return errs, nil
+}
+
+// validateNested 检查值中嵌套的结构体
+func validateNested(path string, v reflect.Value, errs []error) ([]error, error) {
+	var err error
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return errs, nil
+		}
+		return validateNested(path, v.Elem(), errs)
+	case reflect.Struct:
+		if wrapKindOf(v.Type()) != wrapNone {
+			if !v.Field(1).Bool() {
+				return errs, nil
+			}
+			return validateNested(path, v.Field(0), errs)
+		}
+		if _, ok := lookupEncoder(v.Type()); ok || v.Type() == decimalType {
+			return errs, nil
+		}
+		return validateStruct(path, v, errs)
+	case reflect.Slice, reflect.Array:
+		if !mayContainStruct(v.Type().Elem()) {
+			return errs, nil
+		}
+		for i := 0; i < v.Len(); i++ {
+			if errs, err = validateNested(joinPath(path, indexSeg(i)), v.Index(i), errs); err != nil {
+				return nil, err
+			}
+		}
+	case reflect.Map:
+		if !mayContainStruct(v.Type().Elem()) {
+			return errs, nil
+		}
+		// 按 key 排序，保证报告的顺序稳定
+		values := make(map[string]reflect.Value, v.Len())
+		keys := make([]string, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key, err := formatMapKey(iter.Key())
+			if err != nil {
+				return nil, err
+			}
+			values[key] = iter.Value()
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if errs, err = validateNested(joinPath(path, key), values[key], errs); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return errs, nil
+}
+
+// mayContainStruct 报告 t 类型的值中是否可能有需要检查的结构体
+func mayContainStruct(t reflect.Type) bool {
+	for {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+			t = t.Elem()
+		case reflect.Struct, reflect.Interface:
+			return true
+		default:
+			return false
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package sfs
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type betLimits struct {
+	BetLevel int32             `sfs:"betLevel,min=1,max=10"`
+	Lines    []int16           `sfs:"lines,maxlen=3"`
+	Currency string            `sfs:"currency,len=3"`
+	Mode     string            `sfs:"mode,oneof=normal|turbo"`
+	Speed    int16             `sfs:"speed,oneof=1|2|4"`
+	Amount   Decimal           `sfs:"amount,decimal=2,min=0.01,max=1000"`
+	Nickname Optional[string]  `sfs:"nickname,maxlen=4"`
+	Token    string            `sfs:"token,pattern=^[a-f0-9]{2,4}(,[a-f0-9]{2,4})*$"`
+	Extra    *betLimitsExtra   `sfs:"extra,optional"`
+	Chips    map[string]uint16 `sfs:"chips,optional,len=1"`
+}
+
+type betLimitsExtra struct {
+	Ratio float64 `sfs:"ratio,min=0,max=1"`
+}
+
+func TestConstraints(t *testing.T) {
+	valid := SFSObject{
+		"betLevel": int32(5),
+		"lines":    []int16{1, 2},
+		"currency": "EUR",
+		"mode":     "turbo",
+		"speed":    int16(4),
+		"amount":   int64(250),
+		"token":    "ab,c0ff",
+		"extra":    SFSObject{"ratio": 0.5},
+	}
+	var req betLimits
+	if err := Unmarshal(valid, &req); err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateStruct(&req); err != nil {
+		t.Fatalf("ValidateStruct: %v", err)
+	}
+
+	invalid := SFSObject{
+		"betLevel": int32(11),
+		"lines":    []int16{1, 2, 3, 4},
+		"currency": "EURO",
+		"mode":     "fast",
+		"speed":    int16(3),
+		"amount":   int64(0),
+		"nickname": "dragon",
+		"token":    "xyz",
+		"extra":    SFSObject{"ratio": 1.5},
+	}
+	err := UnmarshalOptions{ReportAll: true}.Unmarshal(invalid, &req)
+	var me *MultiError
+	if !errors.As(err, &me) {
+		t.Fatalf("expected MultiError, got %v", err)
+	}
+	var paths []string
+	for _, e := range me.Errors {
+		var fe *FieldError
+		if !errors.As(e, &fe) || !errors.Is(e, ErrConstraint) {
+			t.Fatalf("expected FieldError wrapping ErrConstraint, got %v", e)
+		}
+		paths = append(paths, fe.Path)
+	}
+	want := "betLevel lines currency mode speed amount nickname token extra.ratio"
+	if got := strings.Join(paths, " "); got != want {
+		t.Fatalf("paths = %s, want %s", got, want)
+	}
+
+	// 第一个错误处停止
+	if err := Unmarshal(invalid, &req); !errors.Is(err, ErrConstraint) {
+		t.Fatalf("expected ErrConstraint, got %v", err)
+	}
+
+	// ValidateStruct 检查不经过 Unmarshal 的值，包括嵌套结构体
+	req.BetLevel = 0
+	req.Extra = &betLimitsExtra{Ratio: -1}
+	req.Chips = map[string]uint16{"red": 1, "blue": 5}
+	err = ValidateStruct(req)
+	if !errors.As(err, &me) || len(me.Errors) < 3 {
+		t.Fatalf("expected several errors, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "extra.ratio") || !strings.Contains(err.Error(), "chips") {
+		t.Fatalf("missing paths in %v", err)
+	}
+
+	type badConstraints struct {
+		A string  `sfs:"a,min=1"`
+		B int32   `sfs:"b,max=x"`
+		C bool    `sfs:"c,oneof=true"`
+		D int32   `sfs:"d,pattern=^1$"`
+		E string  `sfs:"e,pattern=("`
+		F float64 `sfs:"f,maxlen=2"`
+		G string  `sfs:"g,pattern=^[a-z]+$,optional"`
+		H string  `sfs:"h,pattern=^[a-z]+$,maxlen=3"`
+	}
+	err = Precompile(badConstraints{})
+	for _, field := range []string{".A:", ".B:", ".C:", ".D:", ".E:", ".F:", ".G:", ".H:"} {
+		if err == nil || !strings.Contains(err.Error(), field) {
+			t.Errorf("expected error for %s, got %v", field, err)
+		}
+	}
+}
@@ -0,0 +1,439 @@
+package sfs
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// maxDecimalScale 是 int64 能表示的最大小数位数
+const maxDecimalScale = 18
+
+var pow10 = [maxDecimalScale + 1]int64{
+	1, 10, 100, 1e3, 1e4, 1e5, 1e6, 1e7, 1e8, 1e9,
+	1e10, 1e11, 1e12, 1e13, 1e14, 1e15, 1e16, 1e17, 1e18,
+}
+
+// Decimal 是定点小数，值为 units × 10^-scale，用于金额等不能经过 float64 的字段。
+// 字段必须带 tag 选项 decimal=N，例如 `sfs:"balance,decimal=3,as=LONG"`，否则 Precompile 和 Marshal 报错。
+// 切片、数组和 map 字段的 decimal=/as=/round= 作用于每个元素，例如 `sfs:"wins,decimal=2"` 用于 []Decimal。
+type Decimal struct {
+	units int64
+	scale uint8
+}
+
+// NewDecimal 返回 units × 10^-scale，scale 不在 0..18 之间时返回错误
+func NewDecimal(units int64, scale int) (Decimal, error) {
+	if scale < 0 || scale > maxDecimalScale {
+		return Decimal{}, fmt.Errorf("decimal scale %d out of range", scale)
+	}
+	return Decimal{units: units, scale: uint8(scale)}, nil
+}
+
+// ParseDecimal 解析十进制字符串，例如 "80000000310.400" 或 "-0.5"，保留原有的小数位数
+func ParseDecimal(s string) (Decimal, error) {
+	str := s
+	neg := false
+	if strings.HasPrefix(str, "-") || strings.HasPrefix(str, "+") {
+		neg = str[0] == '-'
+		str = str[1:]
+	}
+	intPart, fracPart, _ := strings.Cut(str, ".")
+	if intPart == "" && fracPart == "" || len(fracPart) > maxDecimalScale {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+
+	var units uint64
+	for _, c := range intPart + fracPart {
+		if c < '0' || c > '9' {
+			return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+		}
+		if units > (math.MaxInt64-uint64(c-'0'))/10 {
+			// -9223372036854775808 本身不能用正数表示，按溢出处理
+			return Decimal{}, fmt.Errorf("decimal %q overflows int64", s)
+		}
+		units = units*10 + uint64(c-'0')
+	}
+
+	d := Decimal{units: int64(units), scale: uint8(len(fracPart))}
+	if neg {
+		d.units = -d.units
+	}
+	return d, nil
+}
+
+// Units 返回未缩放的整数值
+func (d Decimal) Units() int64 {
+	return d.units
+}
+
+// Scale 返回小数位数
+func (d Decimal) Scale() int {
+	return int(d.scale)
+}
+
+// String 返回带 Scale 位小数的十进制字符串
+func (d Decimal) String() string {
+	u := uint64(d.units)
+	sign := ""
+	if d.units < 0 {
+		u = -u
+		sign = "-"
+	}
+	digits := strconv.FormatUint(u, 10)
+	if d.scale == 0 {
+		return sign + digits
+	}
+	scale := int(d.scale)
+	if len(digits) <= scale {
+		digits = strings.Repeat("0", scale-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+}
+
+// RoundingMode 决定减少小数位数时如何舍入
+type RoundingMode byte
+
+const (
+	RoundHalfUp   RoundingMode = iota // 四舍五入，.5 远离零
+	RoundHalfEven                     // 银行家舍入，.5 舍入到偶数
+	RoundDown                         // 截断（向零舍入）
+	RoundExact                        // 需要舍入时返回错误
+)
+
+// Rescale 返回小数位数为 scale 的值。增加位数时可能溢出，减少位数时按 mode 舍入。
+func (d Decimal) Rescale(scale int, mode RoundingMode) (Decimal, error) {
+	if scale < 0 || scale > maxDecimalScale {
+		return Decimal{}, fmt.Errorf("decimal scale %d out of range", scale)
+	}
+	cur := int(d.scale)
+	if scale >= cur {
+		p := pow10[scale-cur]
+		if d.units > math.MaxInt64/p || d.units < math.MinInt64/p {
+			return Decimal{}, fmt.Errorf("decimal %s overflows int64 with %d decimal places", d, scale)
+		}
+		return Decimal{units: d.units * p, scale: uint8(scale)}, nil
+	}
+
+	p := pow10[cur-scale]
+	q, r := d.units/p, d.units%p
+	if r != 0 {
+		half := p / 2
+		abs := r
+		if abs < 0 {
+			abs = -abs
+		}
+		up := false
+		switch mode {
+		case RoundHalfUp:
+			up = abs >= half
+		case RoundHalfEven:
+			up = abs > half || abs == half && q%2 != 0
+		case RoundExact:
+			return Decimal{}, fmt.Errorf("decimal %s cannot be represented with %d decimal places", d, scale)
+		}
+		if up {
+			if r < 0 {
+				q--
+			} else {
+				q++
+			}
+		}
+	}
+	return Decimal{units: q, scale: uint8(scale)}, nil
+}
+
+// Cmp 比较 d 和 e 的数值，d < e 时返回 -1，相等时返回 0，d > e 时返回 1
+func (d Decimal) Cmp(e Decimal) int {
+	if d.scale == e.scale {
+		return cmpOrdered(d.units, e.units)
+	}
+	a := big.NewInt(d.units)
+	b := big.NewInt(e.units)
+	if d.scale < e.scale {
+		a.Mul(a, big.NewInt(pow10[e.scale-d.scale]))
+	} else {
+		b.Mul(b, big.NewInt(pow10[d.scale-e.scale]))
+	}
+	return a.Cmp(b)
+}
+
+// decimalInfo 是 tag 中 decimal=N、as=、round= 选项的内容
+type decimalInfo struct {
+	scale int
+	as    DataType
+	mode  RoundingMode
+}
+
+var decimalType = reflect.TypeOf(Decimal{})
+
+func parseRoundingMode(s string) (RoundingMode, error) {
+	switch s {
+	case "half_up":
+		return RoundHalfUp, nil
+	case "half_even":
+		return RoundHalfEven, nil
+	case "down":
+		return RoundDown, nil
+	case "exact":
+		return RoundExact, nil
+	default:
+		return 0, fmt.Errorf("unknown rounding mode: %s", s)
+	}
+}
+
+// checkDecimalType 检查 decimal 字段的 Go 类型：Decimal、指向它的指针，
+// 或者元素为这些类型的切片、数组和 map（可以嵌套）
+func checkDecimalType(info fieldInfo, t reflect.Type) error {
+	if info.dataType != NULL {
+		return fmt.Errorf("decimal cannot be combined with type=, use as=")
+	}
+	if info.payload != payloadNone {
+		return fmt.Errorf("decimal cannot be combined with %s", info.payload)
+	}
+	elem := t
+	for {
+		switch elem.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Array:
+			elem = elem.Elem()
+			continue
+		case reflect.Map:
+			if k := elem.Key().Kind(); k != reflect.String && !isIntKind(k) && !isUintKind(k) {
+				return fmt.Errorf("unsupported map key type: %s", elem.Key())
+			}
+			elem = elem.Elem()
+			continue
+		}
+		break
+	}
+	if elem != decimalType {
+		return fmt.Errorf("decimal cannot be used with %s", t)
+	}
+	return nil
+}
+
+// checkPlainDecimal 拒绝没有 decimal= 选项的 Decimal 字段（包括切片、数组和 map 的元素），
+// 否则 Decimal 会被当作没有导出字段的结构体编码为空对象；注册了编码器的除外
+func checkPlainDecimal(t reflect.Type) error {
+	for {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+			t = t.Elem()
+			continue
+		}
+		break
+	}
+	if t != decimalType {
+		return nil
+	}
+	if _, ok := lookupEncoder(t); ok {
+		return nil
+	}
+	return fmt.Errorf("%s requires decimal=N", t)
+}
+
+// encodeDecimal 将 Decimal 字段按 tag 指定的小数位数转换为 LONG 或 UTF_STRING；
+// 切片和数组编码为 LONG_ARRAY 或 UTF_STRING_ARRAY（元素是指针或容器时为 SFSArray），map 编码为 SFSObject
+func encodeDecimal(val reflect.Value, info *decimalInfo) (interface{}, error) {
+	for val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return nil, nil
+		}
+		val = val.Elem()
+	}
+	switch val.Kind() {
+	case reflect.Slice, reflect.Array:
+		return encodeDecimalSeq(val, info)
+	case reflect.Map:
+		return encodeDecimalMap(val, info)
+	}
+	d, err := val.Interface().(Decimal).Rescale(info.scale, info.mode)
+	if err != nil {
+		return nil, err
+	}
+	if info.as == UTF_STRING {
+		return d.String(), nil
+	}
+	return d.units, nil
+}
+
+// encodeDecimalSeq 逐个元素编码 Decimal 切片或数组，与 Marshal 一样空切片编码为 NULL
+func encodeDecimalSeq(val reflect.Value, info *decimalInfo) (interface{}, error) {
+	n := val.Len()
+	if n == 0 {
+		return nil, nil
+	}
+	elemType := val.Type().Elem()
+	if elemType == decimalType {
+		units := make([]int64, n)
+		var strs []string
+		if info.as == UTF_STRING {
+			strs = make([]string, n)
+		}
+		for i := 0; i < n; i++ {
+			d, err := val.Index(i).Interface().(Decimal).Rescale(info.scale, info.mode)
+			if err != nil {
+				return nil, prefixPath(err, indexSeg(i), elemType, info.as)
+			}
+			if strs != nil {
+				strs[i] = d.String()
+			} else {
+				units[i] = d.units
+			}
+		}
+		if strs != nil {
+			return strs, nil
+		}
+		return units, nil
+	}
+
+	arr := make(SFSArray, n)
+	for i := 0; i < n; i++ {
+		v, err := encodeDecimal(val.Index(i), info)
+		if err != nil {
+			return nil, prefixPath(err, indexSeg(i), elemType, NULL)
+		}
+		arr[i] = v
+	}
+	return arr, nil
+}
+
+// encodeDecimalMap 将值为 Decimal 的 map 编码为 SFSObject，key 的规则与 Marshal 相同
+func encodeDecimalMap(val reflect.Value, info *decimalInfo) (interface{}, error) {
+	obj := make(SFSObject, val.Len())
+	iter := val.MapRange()
+	for iter.Next() {
+		key, err := formatMapKey(iter.Key())
+		if err != nil {
+			return nil, err
+		}
+		v, err := encodeDecimal(iter.Value(), info)
+		if err != nil {
+			return nil, prefixPath(err, key, iter.Value().Type(), NULL)
+		}
+		obj[key] = v
+	}
+	return obj, nil
+}
+
+// decodeDecimal 将 LONG（缩放后的整数）或十进制字符串写入 Decimal 字段
+func (d *decoder) decodeDecimal(field reflect.Value, sfsValue interface{}, info *decimalInfo) error {
+	if sfsValue == nil {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			field.Set(reflect.New(field.Type().Elem()))
+		}
+		return d.decodeDecimal(field.Elem(), sfsValue, info)
+	}
+	switch field.Kind() {
+	case reflect.Slice, reflect.Array:
+		return d.decodeDecimalSeq(field, sfsValue, info)
+	case reflect.Map:
+		return d.decodeDecimalMap(field, sfsValue, info)
+	}
+
+	var dec Decimal
+	switch v := sfsValue.(type) {
+	case int64:
+		dec = Decimal{units: v, scale: uint8(info.scale)}
+	case int32:
+		dec = Decimal{units: int64(v), scale: uint8(info.scale)}
+	case int16:
+		dec = Decimal{units: int64(v), scale: uint8(info.scale)}
+	case string:
+		parsed, err := ParseDecimal(v)
+		if err != nil {
+			return err
+		}
+		if dec, err = parsed.Rescale(info.scale, info.mode); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("cannot convert %T to decimal", sfsValue)
+	}
+	field.Set(reflect.ValueOf(dec))
+	return nil
+}
+
+// decodeDecimalSeq 将 LONG_ARRAY、INT_ARRAY、SHORT_ARRAY、UTF_STRING_ARRAY 或 SFSArray 逐个元素写入 Decimal 切片或数组
+func (d *decoder) decodeDecimalSeq(field reflect.Value, sfsValue interface{}, info *decimalInfo) error {
+	switch sfsValue.(type) {
+	case []int64, []int32, []int16, []string, SFSArray, []interface{}:
+	default:
+		return fmt.Errorf("cannot convert %T to %s", sfsValue, field.Type())
+	}
+	src := reflect.ValueOf(sfsValue)
+	seq, err := makeSeq(field.Type(), src.Len())
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	elemType := field.Type().Elem()
+	for i := 0; i < src.Len(); i++ {
+		v := src.Index(i).Interface()
+		if err := d.decodeDecimal(seq.Index(i), v, info); err != nil {
+			err = prefixPath(err, indexSeg(i), elemType, wireTypeOf(v))
+			if !d.opts.ReportAll {
+				return err
+			}
+			errs = appendError(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return joinErrors(errs)
+	}
+	field.Set(seq)
+	return nil
+}
+
+// decodeDecimalMap 将 SFSObject 的每个值写入值为 Decimal 的 map
+func (d *decoder) decodeDecimalMap(field reflect.Value, sfsValue interface{}, info *decimalInfo) error {
+	var obj map[string]interface{}
+	switch v := sfsValue.(type) {
+	case SFSObject:
+		obj = v
+	case map[string]interface{}:
+		obj = v
+	default:
+		return fmt.Errorf("cannot convert %T to %s", sfsValue, field.Type())
+	}
+
+	var errs []error
+	mapType := field.Type()
+	m := reflect.MakeMapWithSize(mapType, len(obj))
+	for k, v := range obj {
+		key, err := parseMapKey(mapType.Key(), k)
+		if err != nil {
+			err = prefixPath(err, k, mapType.Key(), UTF_STRING)
+			if !d.opts.ReportAll {
+				return err
+			}
+			errs = appendError(errs, err)
+			continue
+		}
+		elem := reflect.New(mapType.Elem()).Elem()
+		if err := d.decodeDecimal(elem, v, info); err != nil {
+			err = prefixPath(err, k, mapType.Elem(), wireTypeOf(v))
+			if !d.opts.ReportAll {
+				return err
+			}
+			errs = appendError(errs, err)
+			continue
+		}
+		m.SetMapIndex(key, elem)
+	}
+
+	if len(errs) > 0 {
+		return joinErrors(errs)
+	}
+	field.Set(m)
+	return nil
+}
@@ -0,0 +1,191 @@
+package sfs
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// mustDecimal 是测试用的 NewDecimal，scale 不合法时 panic
+func mustDecimal(units int64, scale int) Decimal {
+	d, err := NewDecimal(units, scale)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func TestDecimalRescale(t *testing.T) {
+	cases := []struct {
+		in    string
+		scale int
+		mode  RoundingMode
+		want  string
+	}{
+		{"80000000310.400", 3, RoundHalfUp, "80000000310.400"},
+		{"1.2345", 3, RoundHalfUp, "1.235"},
+		{"-1.2345", 3, RoundHalfUp, "-1.235"},
+		{"1.2345", 3, RoundHalfEven, "1.234"},
+		{"1.2355", 3, RoundHalfEven, "1.236"},
+		{"1.2349", 3, RoundDown, "1.234"},
+		{"-0.005", 2, RoundHalfUp, "-0.01"},
+		{"0.5", 0, RoundHalfEven, "0"},
+		{"7", 2, RoundExact, "7.00"},
+	}
+	for _, c := range cases {
+		d, err := ParseDecimal(c.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := d.Rescale(c.scale, c.mode)
+		if err != nil {
+			t.Fatalf("%s: %v", c.in, err)
+		}
+		if got.String() != c.want {
+			t.Errorf("Rescale(%s, %d, %d) = %s, want %s", c.in, c.scale, c.mode, got, c.want)
+		}
+	}
+
+	if _, err := mustDecimal(1, 1).Rescale(0, RoundExact); err == nil {
+		t.Error("expected error for inexact rescale")
+	}
+	if _, err := mustDecimal(9223372036854775, 0).Rescale(4, RoundHalfUp); err == nil {
+		t.Error("expected overflow error")
+	}
+	if _, err := ParseDecimal("99999999999999999999"); err == nil {
+		t.Error("expected overflow error")
+	}
+	for _, scale := range []int{-1, 19} {
+		if _, err := NewDecimal(1, scale); err == nil {
+			t.Errorf("expected error for scale %d", scale)
+		}
+	}
+}
+
+type account struct {
+	Balance Decimal  `sfs:"balance,decimal=3,as=LONG"`
+	Text    Decimal  `sfs:"text,decimal=2,as=UTF_STRING,round=half_even"`
+	Bonus   *Decimal `sfs:"bonus,decimal=2,optional"`
+}
+
+func TestDecimalTags(t *testing.T) {
+	bal, _ := ParseDecimal("80000000310.4")
+	txt, _ := ParseDecimal("10.125")
+	obj, err := Marshal(account{Balance: bal, Text: txt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj["balance"] != int64(80000000310400) || obj["text"] != "10.12" {
+		t.Fatalf("unexpected encoding: %v", obj)
+	}
+	if _, ok := obj["bonus"]; ok {
+		t.Fatal("nil optional decimal should be omitted")
+	}
+
+	var out account
+	obj["bonus"] = int64(150)
+	if err := Unmarshal(obj, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Balance.String() != "80000000310.400" || out.Text.String() != "10.12" || out.Bonus.String() != "1.50" {
+		t.Fatalf("unexpected decode: %v %v %v", out.Balance, out.Text, out.Bonus)
+	}
+
+	big := mustDecimal(9223372036854776, 0)
+	if _, err := Marshal(account{Balance: big}); err == nil || !strings.Contains(err.Error(), "balance") {
+		t.Fatalf("expected overflow error at balance, got %v", err)
+	}
+
+	type badDecimal struct {
+		A Decimal   `sfs:"a,decimal=19"`
+		B float64   `sfs:"b,decimal=2"`
+		C Decimal   `sfs:"c,as=LONG"`
+		D Decimal   `sfs:"d,decimal=2,as=DOUBLE"`
+		E Decimal   `sfs:"e"`
+		F *Decimal  `sfs:"f"`
+		G []Decimal `sfs:"g"`
+	}
+	err = Precompile(badDecimal{})
+	for _, field := range []string{".A:", ".B:", ".C:", ".D:", ".E:", ".F:", ".G:"} {
+		if err == nil || !strings.Contains(err.Error(), field) {
+			t.Errorf("expected error for %s, got %v", field, err)
+		}
+	}
+
+	// 没有 decimal= 的 Decimal 不能静默编码为空对象
+	type plainDecimal struct {
+		Amount Decimal `sfs:"amount"`
+	}
+	if _, err := Marshal(plainDecimal{Amount: mustDecimal(150, 2)}); err == nil {
+		t.Fatal("expected error for Decimal without decimal=")
+	}
+}
+
+type lineWins struct {
+	Wins    []Decimal            `sfs:"wins,decimal=2"`
+	Text    [2]Decimal           `sfs:"text,decimal=2,as=UTF_STRING"`
+	Ptrs    []*Decimal           `sfs:"ptrs,decimal=2"`
+	ByLine  map[int]Decimal      `sfs:"byLine,decimal=2,as=UTF_STRING"`
+	Nested  [][]Decimal          `sfs:"nested,decimal=1"`
+	ByState map[string][]Decimal `sfs:"byState,decimal=1"`
+}
+
+func TestDecimalContainers(t *testing.T) {
+	dec := func(s string) Decimal {
+		d, err := ParseDecimal(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	p := dec("0.25")
+	in := lineWins{
+		Wins:    []Decimal{dec("1.50"), dec("0.00"), dec("-2.05")},
+		Text:    [2]Decimal{dec("10.12"), dec("0.01")},
+		Ptrs:    []*Decimal{&p, nil},
+		ByLine:  map[int]Decimal{3: dec("7.00")},
+		Nested:  [][]Decimal{{dec("0.1")}, {dec("0.2"), dec("0.3")}},
+		ByState: map[string][]Decimal{"free": {dec("1.5")}},
+	}
+	obj, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w, ok := obj["wins"].([]int64); !ok || !reflect.DeepEqual(w, []int64{150, 0, -205}) {
+		t.Fatalf("wins = %#v, want LONG_ARRAY", obj["wins"])
+	}
+	if s, ok := obj["text"].([]string); !ok || !reflect.DeepEqual(s, []string{"10.12", "0.01"}) {
+		t.Fatalf("text = %#v, want UTF_STRING_ARRAY", obj["text"])
+	}
+	if a, ok := obj["ptrs"].(SFSArray); !ok || !reflect.DeepEqual(a, SFSArray{int64(25), nil}) {
+		t.Fatalf("ptrs = %#v, want SFSArray", obj["ptrs"])
+	}
+	if m, ok := obj["byLine"].(SFSObject); !ok || m["3"] != "7.00" {
+		t.Fatalf("byLine = %#v, want SFSObject", obj["byLine"])
+	}
+
+	var out lineWins
+	roundTrip(t, in, &out)
+
+	// 元素按 round= 舍入，超出范围的元素报告所在的下标
+	rounded, err := Marshal(struct {
+		Wins []Decimal `sfs:"wins,decimal=1,round=down"`
+	}{[]Decimal{dec("1.59")}})
+	if err != nil || !reflect.DeepEqual(rounded["wins"], []int64{15}) {
+		t.Fatalf("rounded = %v, %v", rounded, err)
+	}
+	_, err = Marshal(struct {
+		Wins []Decimal `sfs:"wins,decimal=4"`
+	}{[]Decimal{dec("1"), mustDecimal(9223372036854775, 0)}})
+	var fe *FieldError
+	if !errors.As(err, &fe) || fe.Path != "wins[1]" {
+		t.Fatalf("expected FieldError at wins[1], got %v", err)
+	}
+
+	var bad lineWins
+	err = Unmarshal(SFSObject{"wins": SFSArray{int64(1), "x"}}, &bad)
+	if !errors.As(err, &fe) || fe.Path != "wins[1]" {
+		t.Fatalf("expected FieldError at wins[1], got %v", err)
+	}
+}
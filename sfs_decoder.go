@@ -0,0 +1,505 @@
+package sfs
+
+import (
+	"bufio"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sync/atomic"
+)
+
+// TokenKind 是 Decoder.Token 返回的事件类型
+type TokenKind byte
+
+const (
+	BeginObject TokenKind = iota + 1 // SFS_OBJECT 开始，Len 为 key 数量
+	Key                              // SFS_OBJECT 中的 key
+	Scalar                           // 非容器值（包括 NULL 和定长数组）
+	BeginArray                       // SFS_ARRAY 开始，Len 为元素数量
+	End                              // 当前 SFS_OBJECT / SFS_ARRAY 结束
+)
+
+func (k TokenKind) String() string {
+	switch k {
+	case BeginObject:
+		return "BeginObject"
+	case Key:
+		return "Key"
+	case Scalar:
+		return "Scalar"
+	case BeginArray:
+		return "BeginArray"
+	case End:
+		return "End"
+	default:
+		return fmt.Sprintf("TokenKind(%d)", byte(k))
+	}
+}
+
+// Token 是流式解码的一个事件。
+// Scalar 的 Value 与 Unpacker 解码出的 Go 值相同；End 的 Type 为所结束的容器类型。
+type Token struct {
+	Kind  TokenKind
+	Type  DataType
+	Key   string
+	Len   int
+	Value interface{}
+}
+
+type decodeFrame struct {
+	typ       DataType
+	remaining int
+	index     int
+	key       string
+	keyNext   bool
+}
+
+// Decoder 从 io.Reader 中逐个读取 SFS 数据包的 token（类似 encoding/json.Decoder.Token），
+// 只保留容器的嵌套栈，可以在常量内存下扫描很大的 SFS_ARRAY。
+type Decoder struct {
+	src     io.Reader
+	body    *io.LimitedReader
+	r       *bufio.Reader
+	zr      io.ReadCloser
+	opts    UnpackOptions
+	offset  int
+	stack   []decodeFrame
+	started bool
+	done    bool
+	scratch [8]byte
+	busy    atomic.Bool
+}
+
+// NewDecoder 创建从 r 读取一个数据包（含包头）的 Decoder
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{src: r}
+}
+
+// SetOptions 设置解码选项，需要在第一次调用 Token 之前设置。
+// MaxSize 限制读取的（解压后）对象数据字节数。与 Token 同时调用时返回 ErrConcurrentUse。
+func (d *Decoder) SetOptions(opts UnpackOptions) error {
+	if !d.busy.CompareAndSwap(false, true) {
+		return ErrConcurrentUse
+	}
+	defer d.busy.Store(false)
+	d.opts = opts
+	return nil
+}
+
+// Token 返回下一个 token，根对象结束后返回 io.EOF。
+// 同一个 Decoder 不能被多个 goroutine 同时使用，否则返回 ErrConcurrentUse
+func (d *Decoder) Token() (Token, error) {
+	if !d.busy.CompareAndSwap(false, true) {
+		return Token{}, ErrConcurrentUse
+	}
+	defer d.busy.Store(false)
+	if d.done {
+		return Token{}, io.EOF
+	}
+	if !d.started {
+		d.started = true
+		if err := d.readHeader(); err != nil {
+			d.done = true
+			return Token{}, err
+		}
+		return d.readValue()
+	}
+	if len(d.stack) == 0 {
+		d.done = true
+		return Token{}, io.EOF
+	}
+
+	top := &d.stack[len(d.stack)-1]
+	if top.remaining == 0 {
+		d.stack = d.stack[:len(d.stack)-1]
+		if len(d.stack) == 0 {
+			d.done = true
+			if err := d.finish(); err != nil {
+				return Token{}, err
+			}
+		}
+		return Token{Kind: End, Type: top.typ}, nil
+	}
+
+	if top.typ == SFS_OBJECT && top.keyNext {
+		start := d.offset
+		key, err := d.readKey()
+		if err != nil {
+			return Token{}, d.fail(start, SFS_OBJECT, err)
+		}
+		top.key = key
+		top.keyNext = false
+		return Token{Kind: Key, Type: UTF_STRING, Key: key}, nil
+	}
+
+	top.remaining--
+	top.index++
+	if top.typ == SFS_OBJECT {
+		top.keyNext = true
+	}
+	return d.readValue()
+}
+
+// More 报告当前容器中是否还有未读取的元素
+func (d *Decoder) More() bool {
+	if len(d.stack) == 0 {
+		return !d.started
+	}
+	top := d.stack[len(d.stack)-1]
+	return top.remaining > 0 || (top.typ == SFS_OBJECT && !top.keyNext)
+}
+
+// Depth 返回当前容器的嵌套深度
+func (d *Decoder) Depth() int {
+	return len(d.stack)
+}
+
+// Path 返回当前位置的路径，例如 p.list[3]
+func (d *Decoder) Path() string {
+	path := ""
+	for _, f := range d.stack {
+		if f.typ == SFS_OBJECT {
+			path = joinPath(path, f.key)
+		} else if f.index > 0 {
+			path = joinPath(path, indexSeg(f.index-1))
+		}
+	}
+	return path
+}
+
+// readHeader 直接从 src 读取包头，只把包体交给 bufio，
+// 不会读到 src 中下一个数据包的字节
+func (d *Decoder) readHeader() error {
+	if _, err := io.ReadFull(d.src, d.scratch[:1]); err != nil {
+		return &DecodeError{Offset: 0, Type: NULL, Err: truncated(err)}
+	}
+	first := d.scratch[0]
+
+	var dataLength int64
+	if first&8 > 0 {
+		if _, err := io.ReadFull(d.src, d.scratch[:4]); err != nil {
+			return &DecodeError{Offset: 1, Type: NULL, Err: truncated(err)}
+		}
+		dataLength = int64(binary.BigEndian.Uint32(d.scratch[:4]))
+	} else {
+		if _, err := io.ReadFull(d.src, d.scratch[:2]); err != nil {
+			return &DecodeError{Offset: 1, Type: NULL, Err: truncated(err)}
+		}
+		dataLength = int64(binary.BigEndian.Uint16(d.scratch[:2]))
+	}
+
+	d.body = &io.LimitedReader{R: d.src, N: dataLength}
+	var body io.Reader = d.body
+	if first&32 > 0 {
+		zr, err := zlib.NewReader(body)
+		if err != nil {
+			return &DecodeError{Offset: 0, Type: NULL, Err: truncated(err)}
+		}
+		d.zr = zr
+		body = zr
+	}
+	d.r = bufio.NewReader(body)
+	return nil
+}
+
+func (d *Decoder) close() {
+	if d.zr != nil {
+		d.zr.Close()
+		d.zr = nil
+	}
+}
+
+// finish 在根对象结束后读完包体剩余的字节，使 src 停在下一个数据包的开头。
+// 压缩的数据包要读到 zlib 流的末尾才会检查 adler32 校验和。
+func (d *Decoder) finish() error {
+	if d.zr != nil {
+		if _, err := io.Copy(io.Discard, d.r); err != nil {
+			return d.fail(d.offset, NULL, err)
+		}
+	}
+	d.close()
+	if d.body != nil {
+		io.Copy(io.Discard, d.body)
+	}
+	return nil
+}
+
+func (d *Decoder) fail(offset int, typ DataType, err error) error {
+	d.done = true
+	d.close()
+	switch e := err.(type) {
+	case *DecodeError:
+		return err
+	case *FieldError:
+		// 数组元素的错误带有下标路径，与 Unpacker 相同
+		return &DecodeError{Offset: offset, Path: joinPath(d.Path(), e.Path), Type: typ, Err: truncated(e.Err)}
+	}
+	return &DecodeError{Offset: offset, Path: d.Path(), Type: typ, Err: truncated(err)}
+}
+
+func (d *Decoder) readValue() (Token, error) {
+	start := d.offset
+	b, err := d.readByte()
+	if err != nil {
+		return Token{}, d.fail(start, NULL, err)
+	}
+
+	typ := DataType(b)
+	switch typ {
+	case SFS_OBJECT, SFS_ARRAY:
+		count, err := d.readUint16()
+		if err != nil {
+			return Token{}, d.fail(start, typ, err)
+		}
+		d.stack = append(d.stack, decodeFrame{typ: typ, remaining: int(count), keyNext: true})
+		if typ == SFS_OBJECT {
+			return Token{Kind: BeginObject, Type: typ, Len: int(count)}, nil
+		}
+		return Token{Kind: BeginArray, Type: typ, Len: int(count)}, nil
+	}
+
+	value, err := d.readScalar(typ)
+	if err != nil {
+		return Token{}, d.fail(start, typ, err)
+	}
+	if len(d.stack) == 0 {
+		d.done = true
+		if err := d.finish(); err != nil {
+			return Token{}, err
+		}
+	}
+	return Token{Kind: Scalar, Type: typ, Value: value}, nil
+}
+
+func (d *Decoder) readScalar(typ DataType) (interface{}, error) {
+	switch typ {
+	case NULL:
+		return nil, nil
+	case BOOL:
+		b, err := d.readByte()
+		return b != 0, err
+	case BYTE:
+		return d.readByte()
+	case SHORT:
+		n, err := d.readUint16()
+		return int16(n), err
+	case INT:
+		n, err := d.readUint32()
+		return int32(n), err
+	case LONG:
+		n, err := d.readUint64()
+		return int64(n), err
+	case FLOAT:
+		n, err := d.readUint32()
+		return math.Float32frombits(n), err
+	case DOUBLE:
+		n, err := d.readUint64()
+		return math.Float64frombits(n), err
+	case UTF_STRING:
+		n, err := d.readUint16()
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.readBytes(int(n))
+		if err != nil {
+			return nil, err
+		}
+		return decodeString(b, d.opts.Strings, d.opts.InvalidUTF8)
+	case TEXT:
+		n, err := d.readUint32()
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.readBytes(int(n))
+		if err != nil {
+			return nil, err
+		}
+		return decodeString(b, StandardUTF8, d.opts.InvalidUTF8)
+	case BYTE_ARRAY:
+		n, err := d.readUint32()
+		if err != nil {
+			return nil, err
+		}
+		return d.readBytes(int(n))
+	}
+
+	if elemSize(typ) == 0 && typ != UTF_STRING_ARRAY {
+		return nil, ErrUnknownType
+	}
+	count, err := d.readUint16()
+	if err != nil {
+		return nil, err
+	}
+	n := int(count)
+	switch typ {
+	case BOOL_ARRAY:
+		arr := make([]bool, n)
+		for i := range arr {
+			b, err := d.readByte()
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = b != 0
+		}
+		return arr, nil
+	case SHORT_ARRAY:
+		arr := make([]int16, n)
+		for i := range arr {
+			v, err := d.readUint16()
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = int16(v)
+		}
+		return arr, nil
+	case INT_ARRAY:
+		arr := make([]int32, n)
+		for i := range arr {
+			v, err := d.readUint32()
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = int32(v)
+		}
+		return arr, nil
+	case LONG_ARRAY:
+		arr := make([]int64, n)
+		for i := range arr {
+			v, err := d.readUint64()
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = int64(v)
+		}
+		return arr, nil
+	case FLOAT_ARRAY:
+		arr := make([]float32, n)
+		for i := range arr {
+			v, err := d.readUint32()
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = math.Float32frombits(v)
+		}
+		return arr, nil
+	case DOUBLE_ARRAY:
+		arr := make([]float64, n)
+		for i := range arr {
+			v, err := d.readUint64()
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = math.Float64frombits(v)
+		}
+		return arr, nil
+	case UTF_STRING_ARRAY:
+		arr := make([]string, n)
+		for i := range arr {
+			l, err := d.readUint16()
+			if err != nil {
+				return nil, err
+			}
+			b, err := d.readBytes(int(l))
+			if err != nil {
+				return nil, err
+			}
+			if arr[i], err = decodeString(b, d.opts.Strings, d.opts.InvalidUTF8); err != nil {
+				return nil, prefixPath(err, indexSeg(i), nil, UTF_STRING_ARRAY)
+			}
+		}
+		return arr, nil
+	default:
+		return nil, ErrUnknownType
+	}
+}
+
+func (d *Decoder) readKey() (string, error) {
+	n, err := d.readUint16()
+	if err != nil {
+		return "", err
+	}
+	if n > maxKeyLength {
+		return "", fmt.Errorf("%w: key length %d", ErrLimitExceeded, n)
+	}
+	b, err := d.readBytes(int(n))
+	if err != nil {
+		return "", err
+	}
+	return decodeString(b, d.opts.Strings, d.opts.InvalidUTF8)
+}
+
+// checkSize 在 MaxSize 大于 0 且再读取 n 个字节会超出时返回 ErrLimitExceeded
+func (d *Decoder) checkSize(n int) error {
+	if d.opts.MaxSize > 0 && d.offset+n > d.opts.MaxSize {
+		return fmt.Errorf("%w: data size exceeds %d", ErrLimitExceeded, d.opts.MaxSize)
+	}
+	return nil
+}
+
+func (d *Decoder) readByte() (byte, error) {
+	if err := d.checkSize(1); err != nil {
+		return 0, err
+	}
+	b, err := d.r.ReadByte()
+	if err == nil {
+		d.offset++
+	}
+	return b, err
+}
+
+func (d *Decoder) readFixed(n int) ([]byte, error) {
+	if err := d.checkSize(n); err != nil {
+		return nil, err
+	}
+	b := d.scratch[:n]
+	read, err := io.ReadFull(d.r, b)
+	d.offset += read
+	return b, err
+}
+
+func (d *Decoder) readUint16() (uint16, error) {
+	b, err := d.readFixed(2)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint16(b), nil
+}
+
+func (d *Decoder) readUint32() (uint32, error) {
+	b, err := d.readFixed(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(b), nil
+}
+
+func (d *Decoder) readUint64() (uint64, error) {
+	b, err := d.readFixed(8)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(b), nil
+}
+
+// readBytes 读取 n 个字节到新分配的切片中。
+// 较长的数据按实际读到的内容逐步扩容，避免损坏的长度字段导致一次性大量分配。
+func (d *Decoder) readBytes(n int) ([]byte, error) {
+	if err := d.checkSize(n); err != nil {
+		return nil, err
+	}
+	if n <= 4096 {
+		b := make([]byte, n)
+		read, err := io.ReadFull(d.r, b)
+		d.offset += read
+		return b, err
+	}
+
+	b, err := io.ReadAll(io.LimitReader(d.r, int64(n)))
+	d.offset += len(b)
+	if err == nil && len(b) < n {
+		err = io.ErrUnexpectedEOF
+	}
+	return b, err
+}
@@ -0,0 +1,169 @@
+package sfs
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// buildFromTokens 用 token 流重建与 Unpacker 相同的值
+func buildFromTokens(t *testing.T, dec *Decoder) interface{} {
+	tok, err := dec.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	switch tok.Kind {
+	case Scalar:
+		return tok.Value
+	case BeginObject:
+		obj := make(SFSObject, tok.Len)
+		for {
+			key, err := dec.Token()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if key.Kind == End {
+				return obj
+			}
+			obj[key.Key] = buildFromTokens(t, dec)
+		}
+	case BeginArray:
+		arr := make(SFSArray, 0, tok.Len)
+		for dec.More() {
+			arr = append(arr, buildFromTokens(t, dec))
+		}
+		if end, err := dec.Token(); err != nil || end.Kind != End {
+			t.Fatalf("expected End, got %v %v", end, err)
+		}
+		return arr
+	}
+	t.Fatalf("unexpected token %v", tok)
+	return nil
+}
+
+func TestDecoderTokens(t *testing.T) {
+	obj := SFSObject{
+		"a": byte(13),
+		"c": int16(1),
+		"p": SFSObject{
+			"c":     "h5.spinResponse",
+			"rows":  SFSArray{SFSObject{"id": int64(1), "w": []float64{1.5}}, SFSObject{"id": int64(2)}},
+			"flags": []bool{true, false},
+			"names": []string{"a", "bc"},
+			"blob":  bytes.Repeat([]byte{7}, 5000),
+		},
+	}
+
+	for _, compress := range []bool{false, true} {
+		data, err := NewPacker().Pack(obj, compress)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		dec := NewDecoder(bytes.NewReader(data))
+		got := buildFromTokens(t, dec)
+		if !reflect.DeepEqual(got, obj) {
+			t.Fatalf("compress=%v: token stream mismatch:\n got: %v\nwant: %v", compress, got, obj)
+		}
+		if _, err := dec.Token(); err != io.EOF {
+			t.Fatalf("expected io.EOF after root object, got %v", err)
+		}
+	}
+
+	data, _ := NewPacker().Pack(obj, false)
+	dec := NewDecoder(bytes.NewReader(data[:len(data)-10]))
+	var err error
+	for err == nil {
+		_, err = dec.Token()
+	}
+	if !errors.Is(err, ErrTruncated) {
+		t.Fatalf("expected ErrTruncated, got %v", err)
+	}
+
+	dec = NewDecoder(bytes.NewReader(data))
+	dec.SetOptions(UnpackOptions{MaxSize: 1000})
+	for err = nil; err == nil; {
+		_, err = dec.Token()
+	}
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("expected ErrLimitExceeded, got %v", err)
+	}
+
+	dec = NewDecoder(bytes.NewReader(data))
+	dec.busy.Store(true)
+	if err := dec.SetOptions(UnpackOptions{}); !errors.Is(err, ErrConcurrentUse) {
+		t.Fatalf("SetOptions: expected ErrConcurrentUse, got %v", err)
+	}
+	if _, err := dec.Token(); !errors.Is(err, ErrConcurrentUse) {
+		t.Fatalf("Token: expected ErrConcurrentUse, got %v", err)
+	}
+
+	// UTF_STRING_ARRAY 元素的错误与 Unpacker 报告相同的路径和偏移
+	data, _ = Pack(SFSObject{"p": SFSObject{"names": []string{"a", "b\xff"}}}, PackOptions{})
+	opts := UnpackOptions{InvalidUTF8: InvalidUTF8Reject}
+	_, want := Unpack(data, opts)
+	dec = NewDecoder(bytes.NewReader(data))
+	dec.SetOptions(opts)
+	for err = nil; err == nil; {
+		_, err = dec.Token()
+	}
+	var de, wantDE *DecodeError
+	if !errors.As(want, &wantDE) || !errors.As(err, &de) || de.Path != "p.names[1]" ||
+		de.Path != wantDE.Path || de.Offset != wantDE.Offset {
+		t.Fatalf("Decoder error %v, Unpacker error %v", err, want)
+	}
+}
+
+// 多个数据包首尾相接时，每个 Decoder 只读取自己的数据包
+func TestDecoderMultiplePackets(t *testing.T) {
+	objs := []SFSObject{
+		{"a": int32(1), "s": "first"},
+		{"b": bytes.Repeat([]byte{3}, 2000)},
+		{"c": SFSArray{int16(2), "third"}},
+	}
+	var stream []byte
+	for i, obj := range objs {
+		data, err := NewPacker().Pack(obj, i == 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stream = append(stream, data...)
+	}
+
+	r := bytes.NewReader(stream)
+	for i, obj := range objs {
+		dec := NewDecoder(r)
+		if got := buildFromTokens(t, dec); !reflect.DeepEqual(got, obj) {
+			t.Fatalf("packet %d = %v, want %v", i, got, obj)
+		}
+		if _, err := dec.Token(); err != io.EOF {
+			t.Fatalf("packet %d: expected io.EOF, got %v", i, err)
+		}
+	}
+	if r.Len() != 0 {
+		t.Fatalf("%d bytes left unread", r.Len())
+	}
+
+	_, err := NewDecoder(bytes.NewReader([]byte{0x20, 0, 2, 1, 2})).Token()
+	var de *DecodeError
+	if !errors.As(err, &de) {
+		t.Fatalf("expected DecodeError for bad zlib header, got %v", err)
+	}
+
+	// adler32 校验和错误的压缩数据包在最后一个 token 处报错
+	data, err := NewPacker().Pack(objs[1], true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)-1] ^= 0xff
+	dec := NewDecoder(bytes.NewReader(data))
+	for err == nil {
+		_, err = dec.Token()
+	}
+	if !errors.As(err, &de) || !errors.Is(err, zlib.ErrChecksum) {
+		t.Fatalf("expected DecodeError with zlib.ErrChecksum, got %v", err)
+	}
+}
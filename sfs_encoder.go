@@ -0,0 +1,391 @@
+package sfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync/atomic"
+)
+
+// headerReserve 是为包头（标志字节 + 最多 4 字节长度）预留的空间
+const headerReserve = 5
+
+type encodeFrame struct {
+	typ      DataType
+	countPos int
+	count    int
+	hasKey   bool
+}
+
+// Encoder 以 token 的方式逐步写出一个 SFS 数据包，不需要先在内存中构造完整的 SFSObject。
+// 容器的元素数量在 End 时回填，包头长度在 Bytes 时回填，输出与 Packer.Pack 相同。
+//
+// 第一个错误之后的所有调用都返回该错误。
+// Bytes(false) 返回的切片引用内部缓冲区，Reset 之后再写入会覆盖它；需要保留时复制或使用 WriteTo。
+// 与 Packer 一样，同一个 Encoder 不能被多个 goroutine 同时使用，否则返回 ErrConcurrentUse。
+type Encoder struct {
+	buf   []byte
+	stack []encodeFrame
+	enc   encoder
+	done  bool
+	err   error
+	busy  atomic.Bool
+}
+
+func NewEncoder() *Encoder {
+	return &Encoder{buf: make([]byte, headerReserve, 512)}
+}
+
+// SetStrings 设置 key、UTF_STRING 和 UTF_STRING_ARRAY 的编码方式，默认为 StandardUTF8；
+// 与其他方法同时调用时返回 ErrConcurrentUse
+func (e *Encoder) SetStrings(enc StringEncoding) error {
+	if !e.busy.CompareAndSwap(false, true) {
+		return ErrConcurrentUse
+	}
+	defer e.busy.Store(false)
+	e.enc = encoder{modifiedUTF8: enc == ModifiedUTF8}
+	return nil
+}
+
+// Reset 清空已写入的内容，以便复用 Encoder；SetStrings 的设置保留。
+// 与其他方法同时调用时返回 ErrConcurrentUse
+func (e *Encoder) Reset() error {
+	if !e.busy.CompareAndSwap(false, true) {
+		return ErrConcurrentUse
+	}
+	defer e.busy.Store(false)
+	e.buf = e.buf[:headerReserve]
+	e.stack = e.stack[:0]
+	e.done = false
+	e.err = nil
+	return nil
+}
+
+// BeginObject 开始一个 SFS_OBJECT，之后交替调用 Key 和写值的方法，最后调用 End
+func (e *Encoder) BeginObject() error {
+	if !e.busy.CompareAndSwap(false, true) {
+		return ErrConcurrentUse
+	}
+	defer e.busy.Store(false)
+	return e.begin(SFS_OBJECT)
+}
+
+// BeginArray 开始一个 SFS_ARRAY，之后依次写入元素，最后调用 End
+func (e *Encoder) BeginArray() error {
+	if !e.busy.CompareAndSwap(false, true) {
+		return ErrConcurrentUse
+	}
+	defer e.busy.Store(false)
+	return e.begin(SFS_ARRAY)
+}
+
+func (e *Encoder) begin(typ DataType) error {
+	if err := e.beforeValue(); err != nil {
+		return err
+	}
+	e.buf = append(e.buf, byte(typ), 0, 0)
+	e.stack = append(e.stack, encodeFrame{typ: typ, countPos: len(e.buf) - 2})
+	return nil
+}
+
+// Key 写入 SFS_OBJECT 中下一个值的 key
+func (e *Encoder) Key(key string) error {
+	if !e.busy.CompareAndSwap(false, true) {
+		return ErrConcurrentUse
+	}
+	defer e.busy.Store(false)
+	if e.err != nil {
+		return e.err
+	}
+	if len(e.stack) == 0 || e.stack[len(e.stack)-1].typ != SFS_OBJECT {
+		return e.fail(errors.New("sfs: Key called outside of an object"))
+	}
+	top := &e.stack[len(e.stack)-1]
+	if top.hasKey {
+		return e.fail(fmt.Errorf("sfs: Key %q called before the value of the previous key", key))
+	}
+	b, err := e.enc.appendUTF(e.buf, key, maxKeyLength, "key")
+	if err != nil {
+		return e.fail(err)
+	}
+	top.hasKey = true
+	e.buf = b
+	return nil
+}
+
+// End 结束当前的 SFS_OBJECT 或 SFS_ARRAY，并回填元素数量
+func (e *Encoder) End() error {
+	if !e.busy.CompareAndSwap(false, true) {
+		return ErrConcurrentUse
+	}
+	defer e.busy.Store(false)
+	if e.err != nil {
+		return e.err
+	}
+	if len(e.stack) == 0 {
+		return e.fail(errors.New("sfs: End called without a matching Begin"))
+	}
+	top := e.stack[len(e.stack)-1]
+	if top.hasKey {
+		return e.fail(errors.New("sfs: End called after Key without a value"))
+	}
+	if top.count > maxCount {
+		return e.fail(fmt.Errorf("%w: %s with %d elements", ErrLimitExceeded, top.typ, top.count))
+	}
+	binary.BigEndian.PutUint16(e.buf[top.countPos:], uint16(top.count))
+	e.stack = e.stack[:len(e.stack)-1]
+	if len(e.stack) == 0 {
+		e.done = true
+	}
+	return nil
+}
+
+// beforeValue 检查当前位置是否可以写入一个值，并为所在容器计数
+func (e *Encoder) beforeValue() error {
+	if e.err != nil {
+		return e.err
+	}
+	if len(e.stack) == 0 {
+		if e.done || len(e.buf) > headerReserve {
+			return e.fail(errors.New("sfs: value written after the root value"))
+		}
+		return nil
+	}
+	top := &e.stack[len(e.stack)-1]
+	if top.typ == SFS_OBJECT {
+		if !top.hasKey {
+			return e.fail(errors.New("sfs: object value written without a Key"))
+		}
+		top.hasKey = false
+	}
+	top.count++
+	return nil
+}
+
+func (e *Encoder) fail(err error) error {
+	if e.err == nil {
+		e.err = err
+	}
+	return e.err
+}
+
+func (e *Encoder) WriteNull() error {
+	if !e.busy.CompareAndSwap(false, true) {
+		return ErrConcurrentUse
+	}
+	defer e.busy.Store(false)
+	if err := e.beforeValue(); err != nil {
+		return err
+	}
+	e.buf = append(e.buf, byte(NULL))
+	return nil
+}
+
+func (e *Encoder) WriteBool(v bool) error {
+	if !e.busy.CompareAndSwap(false, true) {
+		return ErrConcurrentUse
+	}
+	defer e.busy.Store(false)
+	if err := e.beforeValue(); err != nil {
+		return err
+	}
+	var b byte
+	if v {
+		b = 1
+	}
+	e.buf = append(e.buf, byte(BOOL), b)
+	return nil
+}
+
+func (e *Encoder) WriteByte(v byte) error {
+	if !e.busy.CompareAndSwap(false, true) {
+		return ErrConcurrentUse
+	}
+	defer e.busy.Store(false)
+	if err := e.beforeValue(); err != nil {
+		return err
+	}
+	e.buf = append(e.buf, byte(BYTE), v)
+	return nil
+}
+
+func (e *Encoder) WriteShort(v int16) error {
+	if !e.busy.CompareAndSwap(false, true) {
+		return ErrConcurrentUse
+	}
+	defer e.busy.Store(false)
+	if err := e.beforeValue(); err != nil {
+		return err
+	}
+	e.buf = append(e.buf, byte(SHORT))
+	e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(v))
+	return nil
+}
+
+func (e *Encoder) WriteInt(v int32) error {
+	if !e.busy.CompareAndSwap(false, true) {
+		return ErrConcurrentUse
+	}
+	defer e.busy.Store(false)
+	if err := e.beforeValue(); err != nil {
+		return err
+	}
+	e.buf = append(e.buf, byte(INT))
+	e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(v))
+	return nil
+}
+
+func (e *Encoder) WriteLong(v int64) error {
+	if !e.busy.CompareAndSwap(false, true) {
+		return ErrConcurrentUse
+	}
+	defer e.busy.Store(false)
+	if err := e.beforeValue(); err != nil {
+		return err
+	}
+	e.buf = append(e.buf, byte(LONG))
+	e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(v))
+	return nil
+}
+
+func (e *Encoder) WriteFloat(v float32) error {
+	if !e.busy.CompareAndSwap(false, true) {
+		return ErrConcurrentUse
+	}
+	defer e.busy.Store(false)
+	if err := e.beforeValue(); err != nil {
+		return err
+	}
+	e.buf = append(e.buf, byte(FLOAT))
+	e.buf = binary.BigEndian.AppendUint32(e.buf, math.Float32bits(v))
+	return nil
+}
+
+func (e *Encoder) WriteDouble(v float64) error {
+	if !e.busy.CompareAndSwap(false, true) {
+		return ErrConcurrentUse
+	}
+	defer e.busy.Store(false)
+	if err := e.beforeValue(); err != nil {
+		return err
+	}
+	e.buf = append(e.buf, byte(DOUBLE))
+	e.buf = binary.BigEndian.AppendUint64(e.buf, math.Float64bits(v))
+	return nil
+}
+
+// WriteUTF 写入一个 UTF_STRING
+func (e *Encoder) WriteUTF(v string) error {
+	if !e.busy.CompareAndSwap(false, true) {
+		return ErrConcurrentUse
+	}
+	defer e.busy.Store(false)
+	if err := e.beforeValue(); err != nil {
+		return err
+	}
+	b, err := e.enc.appendUTF(append(e.buf, byte(UTF_STRING)), v, maxStringLength, "string")
+	if err != nil {
+		return e.fail(err)
+	}
+	e.buf = b
+	return nil
+}
+
+func (e *Encoder) WriteBytes(v []byte) error {
+	if !e.busy.CompareAndSwap(false, true) {
+		return ErrConcurrentUse
+	}
+	defer e.busy.Store(false)
+	if uint64(len(v)) > math.MaxUint32 {
+		return e.fail(fmt.Errorf("%w: %d bytes", ErrLimitExceeded, len(v)))
+	}
+	if err := e.beforeValue(); err != nil {
+		return err
+	}
+	e.buf = append(e.buf, byte(BYTE_ARRAY))
+	e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(len(v)))
+	e.buf = append(e.buf, v...)
+	return nil
+}
+
+// WriteValue 写入任意 Packer 支持的值（包括定长数组、SFSObject 和 SFSArray）
+func (e *Encoder) WriteValue(v interface{}) error {
+	if !e.busy.CompareAndSwap(false, true) {
+		return ErrConcurrentUse
+	}
+	defer e.busy.Store(false)
+	if err := e.beforeValue(); err != nil {
+		return err
+	}
+	b, err := e.enc.appendValue(e.buf, v)
+	if err != nil {
+		return e.fail(err)
+	}
+	e.buf = b
+	return nil
+}
+
+// Bytes 回填包头并返回完整的数据包。根值必须已经写完（所有 Begin 都已 End）。
+// 未压缩时返回的切片引用 Encoder 的内部缓冲区，在下一次 Reset 之前有效。
+func (e *Encoder) Bytes(compress bool) ([]byte, error) {
+	if !e.busy.CompareAndSwap(false, true) {
+		return nil, ErrConcurrentUse
+	}
+	defer e.busy.Store(false)
+	return e.packet(compress)
+}
+
+// packet 是 Bytes 的实现，调用方负责并发检查
+func (e *Encoder) packet(compress bool) ([]byte, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	if !e.done && (len(e.stack) > 0 || len(e.buf) == headerReserve) {
+		return nil, errors.New("sfs: incomplete value")
+	}
+
+	body := e.buf[headerReserve:]
+	if compress {
+		var compressed bytes.Buffer
+		compressed.Write(make([]byte, headerReserve))
+		if err := deflate(&compressed, body); err != nil {
+			return nil, err
+		}
+		return putHeader(compressed.Bytes(), true), nil
+	}
+	return putHeader(e.buf, false), nil
+}
+
+// putHeader 在 packet 开头预留的 headerReserve 字节中写入包头，返回包头起始的切片
+func putHeader(packet []byte, compressed bool) []byte {
+	var firstByte byte
+	if compressed {
+		firstByte |= 32
+	}
+	n := len(packet) - headerReserve
+	if n > math.MaxUint16 {
+		packet[0] = firstByte | 8
+		binary.BigEndian.PutUint32(packet[1:], uint32(n))
+		return packet
+	}
+	packet[2] = firstByte
+	binary.BigEndian.PutUint16(packet[3:], uint16(n))
+	return packet[2:]
+}
+
+// WriteTo 将完整的未压缩数据包写入 w
+func (e *Encoder) WriteTo(w io.Writer) (int64, error) {
+	if !e.busy.CompareAndSwap(false, true) {
+		return 0, ErrConcurrentUse
+	}
+	defer e.busy.Store(false)
+	packet, err := e.packet(false)
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(packet)
+	return int64(n), err
+}
@@ -0,0 +1,139 @@
+package sfs
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestEncoderMatchesPacker(t *testing.T) {
+	// 每个对象只有一个 key，Packer 的输出顺序是确定的
+	obj := SFSObject{
+		"p": SFSObject{
+			"rows": SFSArray{
+				SFSObject{"id": int64(1)},
+				int32(-7),
+				"x",
+				[]int16{1, 2},
+				SFSArray{},
+			},
+		},
+	}
+
+	enc := NewEncoder()
+	enc.BeginObject()
+	enc.Key("p")
+	enc.BeginObject()
+	enc.Key("rows")
+	enc.BeginArray()
+	enc.BeginObject()
+	enc.Key("id")
+	enc.WriteLong(1)
+	enc.End()
+	enc.WriteInt(-7)
+	enc.WriteUTF("x")
+	enc.WriteValue([]int16{1, 2})
+	enc.BeginArray()
+	enc.End()
+	enc.End()
+	enc.End()
+	if err := enc.End(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, compress := range []bool{false, true} {
+		got, err := enc.Bytes(compress)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := NewPacker().Pack(obj, compress)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("compress=%v: encoder output differs from Packer:\n got: %x\nwant: %x", compress, got, want)
+		}
+		back, err := NewUnpacker(got).Unpack()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(back, obj) {
+			t.Fatalf("compress=%v: round trip mismatch: %v", compress, back)
+		}
+	}
+
+	// 大数组使用 4 字节长度
+	enc.Reset()
+	enc.BeginObject()
+	enc.Key("rows")
+	enc.BeginArray()
+	for i := 0; i < 20000; i++ {
+		enc.WriteInt(int32(i))
+	}
+	enc.End()
+	enc.End()
+	data, err := enc.Bytes(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data[0]&8 == 0 {
+		t.Fatalf("expected 4-byte length header, got flag %#x", data[0])
+	}
+	back, err := NewUnpacker(data).Unpack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows := back.(SFSObject)["rows"].(SFSArray); len(rows) != 20000 || rows[19999] != int32(19999) {
+		t.Fatalf("unexpected rows: len=%d", len(rows))
+	}
+}
+
+func TestEncoderMisuse(t *testing.T) {
+	enc := NewEncoder()
+	enc.BeginObject()
+	if err := enc.WriteInt(1); err == nil {
+		t.Fatal("expected error for object value without key")
+	}
+	// 错误是粘滞的
+	if err := enc.Key("a"); err == nil {
+		t.Fatal("expected the first error to be returned again")
+	}
+
+	enc.Reset()
+	enc.BeginArray()
+	if err := enc.Key("a"); err == nil {
+		t.Fatal("expected error for Key inside an array")
+	}
+
+	enc.Reset()
+	enc.BeginObject()
+	if _, err := enc.Bytes(false); err == nil {
+		t.Fatal("expected error for unfinished object")
+	}
+
+	// 与 Packer 相同，同时使用时返回 ErrConcurrentUse，且不影响之后的调用
+	enc.Reset()
+	enc.busy.Store(true)
+	for name, err := range map[string]error{
+		"SetStrings": enc.SetStrings(ModifiedUTF8),
+		"Reset":      enc.Reset(),
+		"BeginArray": enc.BeginArray(),
+		"WriteUTF":   enc.WriteUTF("x"),
+	} {
+		if !errors.Is(err, ErrConcurrentUse) {
+			t.Errorf("%s: expected ErrConcurrentUse, got %v", name, err)
+		}
+	}
+	if _, err := enc.Bytes(false); !errors.Is(err, ErrConcurrentUse) {
+		t.Errorf("Bytes: expected ErrConcurrentUse, got %v", err)
+	}
+	enc.busy.Store(false)
+	if err := enc.BeginArray(); err != nil {
+		t.Fatal(err)
+	}
+	enc.End()
+	if _, err := enc.Bytes(false); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,114 @@
+package sfs
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// EnumSpec 描述枚举类型 T 的取值和线上表示
+type EnumSpec[T comparable] struct {
+	// As 是线上的数据类型：BYTE、SHORT 使用 Codes，UTF_STRING 使用 Names
+	As DataType
+	// Codes 是每个值的数字编码，BYTE 为 0..255，SHORT 为 -32768..32767
+	Codes map[T]int
+	// Names 是每个值的字符串表示
+	Names map[T]string
+	// Fallback 非 nil 时，解码遇到未知的编码得到 *Fallback 而不是返回 ErrUnknownEnum，
+	// 用于兼容服务端新增的取值
+	Fallback *T
+}
+
+// RegisterEnum 注册枚举类型 T，Marshal/Unmarshal 以及 SFSObject/SFSArray 中的 T 类型值
+// 按 spec 编码为 BYTE、SHORT 或 UTF_STRING，[]T 编码为 BYTE_ARRAY、SHORT_ARRAY 或 UTF_STRING_ARRAY。例如：
+//
+//	sfs.RegisterEnum(sfs.EnumSpec[HitDirection]{
+//		As:    sfs.BYTE,
+//		Codes: map[HitDirection]int{LeftToRight: 0, RightToLeft: 1},
+//	})
+//
+// 编码 spec 中没有的值时返回 ErrUnknownEnum。应在程序启动时注册。
+func RegisterEnum[T comparable](spec EnumSpec[T]) error {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	wire := make(map[T]interface{})
+	values := make(map[interface{}]T)
+
+	switch spec.As {
+	case BYTE, SHORT:
+		if len(spec.Names) > 0 {
+			return fmt.Errorf("enum %s: Names cannot be used with %s", t, spec.As)
+		}
+		for v, code := range spec.Codes {
+			var w interface{}
+			if spec.As == BYTE {
+				if code < 0 || code > 255 {
+					return fmt.Errorf("enum %s: code %d out of range for BYTE", t, code)
+				}
+				w = byte(code)
+			} else {
+				if code < -1<<15 || code > 1<<15-1 {
+					return fmt.Errorf("enum %s: code %d out of range for SHORT", t, code)
+				}
+				w = int16(code)
+			}
+			wire[v] = w
+			values[int64(code)] = v
+		}
+	case UTF_STRING:
+		if len(spec.Codes) > 0 {
+			return fmt.Errorf("enum %s: Codes cannot be used with %s", t, spec.As)
+		}
+		for v, name := range spec.Names {
+			wire[v] = name
+			values[name] = v
+		}
+	default:
+		return fmt.Errorf("enum %s: As must be BYTE, SHORT or UTF_STRING, got %s", t, spec.As)
+	}
+	if len(values) != len(wire) {
+		return fmt.Errorf("enum %s: duplicate codes", t)
+	}
+
+	var fallback *T
+	if spec.Fallback != nil {
+		v := *spec.Fallback
+		fallback = &v
+	}
+
+	RegisterEncoder(t, func(v reflect.Value) (interface{}, error) {
+		w, ok := wire[v.Interface().(T)]
+		if !ok {
+			return nil, fmt.Errorf("%w: %v for %s", ErrUnknownEnum, v.Interface(), t)
+		}
+		return w, nil
+	})
+	wireTypes.Store(t, spec.As)
+	RegisterDecoder(t, func(sfsValue interface{}, v reflect.Value) error {
+		// 线上的类型与 As 不符（如 UTF_STRING 枚举收到数字）时报错，不使用 Fallback
+		var key interface{}
+		switch w := sfsValue.(type) {
+		case byte:
+			key = int64(w)
+		case int16:
+			key = int64(w)
+		case int32:
+			key = int64(w)
+		case int64:
+			key = w
+		case string:
+			key = w
+		}
+		if _, isName := key.(string); key == nil || isName != (spec.As == UTF_STRING) {
+			return fmt.Errorf("cannot convert %T to enum %s", sfsValue, t)
+		}
+		value, ok := values[key]
+		if !ok {
+			if fallback == nil {
+				return fmt.Errorf("%w: %v for %s", ErrUnknownEnum, sfsValue, t)
+			}
+			value = *fallback
+		}
+		v.Set(reflect.ValueOf(value))
+		return nil
+	})
+	return nil
+}
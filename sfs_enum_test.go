@@ -0,0 +1,136 @@
+package sfs
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type hitDirection string
+
+const (
+	leftToRight hitDirection = "LeftToRight"
+	rightToLeft hitDirection = "RightToLeft"
+	bothWays    hitDirection = "BothWays"
+)
+
+type bigWinType int
+
+const (
+	winNormal bigWinType = iota
+	winBig
+	winMega
+	winUnknown
+)
+
+type spinResult struct {
+	Direction  hitDirection   `sfs:"hitDirection"`
+	BigWin     bigWinType     `sfs:"bigWinType"`
+	Directions []hitDirection `sfs:"directions,optional"`
+	History    []bigWinType   `sfs:"history,optional"`
+}
+
+func TestEnum(t *testing.T) {
+	err := RegisterEnum(EnumSpec[hitDirection]{
+		As:    BYTE,
+		Codes: map[hitDirection]int{leftToRight: 0, rightToLeft: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	unknown := winUnknown
+	err = RegisterEnum(EnumSpec[bigWinType]{
+		As:       UTF_STRING,
+		Names:    map[bigWinType]string{winNormal: "normal", winBig: "big", winMega: "mega"},
+		Fallback: &unknown,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in := spinResult{
+		Direction:  rightToLeft,
+		BigWin:     winMega,
+		Directions: []hitDirection{leftToRight, rightToLeft},
+		History:    []bigWinType{winBig, winNormal},
+	}
+	obj, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := SFSObject{
+		"hitDirection": byte(1),
+		"bigWinType":   "mega",
+		"directions":   []byte{0, 1},
+		"history":      []string{"big", "normal"},
+	}
+	if !reflect.DeepEqual(obj, want) {
+		t.Fatalf("Marshal = %v, want %v", obj, want)
+	}
+
+	var out spinResult
+	if err := Unmarshal(obj, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Fatalf("Unmarshal = %+v, want %+v", out, in)
+	}
+
+	// 未知的名称使用 Fallback
+	if err := Unmarshal(SFSObject{"hitDirection": byte(0), "bigWinType": "ultra"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.BigWin != winUnknown {
+		t.Fatalf("BigWin = %v, want fallback", out.BigWin)
+	}
+
+	// SFSObject 中的枚举切片（包括空切片）同样使用基本类型数组
+	data, err := Pack(SFSObject{"d": []hitDirection{rightToLeft}, "e": []bigWinType{}}, PackOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := Unpack(data, UnpackOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (SFSObject{"d": []byte{1}, "e": []string{}}); !reflect.DeepEqual(v, want) {
+		t.Fatalf("Unpack = %#v, want %#v", v, want)
+	}
+
+	// SFSArray 中的编码同样可以解码
+	out = spinResult{}
+	if err := Unmarshal(SFSObject{"hitDirection": byte(0), "bigWinType": "big", "directions": SFSArray{byte(1)}}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out.Directions, []hitDirection{rightToLeft}) {
+		t.Fatalf("Directions = %v", out.Directions)
+	}
+
+	// 线上类型不符时不使用 Fallback
+	if err := Unmarshal(SFSObject{"hitDirection": byte(0), "bigWinType": int32(2)}, &out); err == nil {
+		t.Fatal("expected error for a numeric code of a UTF_STRING enum")
+	}
+	if err := Unmarshal(SFSObject{"hitDirection": "1", "bigWinType": "big"}, &out); err == nil {
+		t.Fatal("expected error for a string code of a BYTE enum")
+	}
+
+	// 没有 Fallback 时未知的编码返回错误
+	err = Unmarshal(SFSObject{"hitDirection": byte(7), "bigWinType": "big"}, &out)
+	if !errors.Is(err, ErrUnknownEnum) {
+		t.Fatalf("expected ErrUnknownEnum, got %v", err)
+	}
+	if _, err := Marshal(spinResult{Direction: bothWays}); !errors.Is(err, ErrUnknownEnum) {
+		t.Fatalf("expected ErrUnknownEnum, got %v", err)
+	}
+
+	bad := []error{
+		RegisterEnum(EnumSpec[hitDirection]{As: BYTE, Codes: map[hitDirection]int{leftToRight: 256}}),
+		RegisterEnum(EnumSpec[hitDirection]{As: SHORT, Codes: map[hitDirection]int{leftToRight: 1, rightToLeft: 1}}),
+		RegisterEnum(EnumSpec[hitDirection]{As: INT, Codes: map[hitDirection]int{leftToRight: 1}}),
+	}
+	for i, err := range bad {
+		if err == nil {
+			t.Errorf("case %d: expected error", i)
+		}
+	}
+}
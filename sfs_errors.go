@@ -0,0 +1,218 @@
+package sfs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrTruncated 表示数据在一个值的中间结束
+	ErrTruncated = errors.New("sfs: truncated data")
+	// ErrUnknownType 表示遇到未知的数据类型标识
+	ErrUnknownType = errors.New("sfs: unknown data type")
+	// ErrLimitExceeded 表示长度或数量超出协议限制
+	ErrLimitExceeded = errors.New("sfs: limit exceeded")
+	// ErrInvalidUTF8 表示字符串不是合法的 UTF-8（UnpackOptions.InvalidUTF8 为 InvalidUTF8Reject 时）
+	ErrInvalidUTF8 = errors.New("sfs: invalid UTF-8 string")
+	// ErrUnsupportedType 表示无法编码的 Go 类型
+	ErrUnsupportedType = errors.New("sfs: unsupported type")
+	// ErrConcurrentUse 表示同一个 Packer、Unpacker、Encoder 或 Decoder 被多个 goroutine 同时使用
+	ErrConcurrentUse = errors.New("sfs: concurrent use of Packer/Unpacker/Encoder/Decoder")
+
+	// ErrUnknownField 表示 SFSObject 中的 key 没有对应的结构体字段
+	ErrUnknownField = errors.New("sfs: unknown field")
+	// ErrMissingField 表示必需的 key 不存在
+	ErrMissingField = errors.New("sfs: required field not found")
+	// ErrUnknownEnum 表示枚举值或线上的编码没有在 RegisterEnum 中注册
+	ErrUnknownEnum = errors.New("sfs: unknown enum value")
+	// ErrUnknownVariant 表示 discriminator 的值或接口中的类型没有在 RegisterVariant 中注册
+	ErrUnknownVariant = errors.New("sfs: unknown variant")
+	// ErrConstraint 表示字段值违反 min=、max=、len=、maxlen=、oneof=、pattern= 约束
+	ErrConstraint = errors.New("sfs: constraint violated")
+)
+
+// DecodeError 描述二进制数据解码失败的位置。
+// Offset 是出错的值（类型字节）在解压后数据中的字节偏移，Path 是从根对象开始的 key 路径。
+type DecodeError struct {
+	Offset int
+	Path   string
+	Type   DataType
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "sfs: cannot decode %s at offset %d", e.Type, e.Offset)
+	if e.Path != "" {
+		sb.WriteString(" (")
+		sb.WriteString(e.Path)
+		sb.WriteString(")")
+	}
+	sb.WriteString(": ")
+	sb.WriteString(e.Err.Error())
+	return sb.String()
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// FieldError 描述 Marshal/Unmarshal 时某个字段的转换错误。
+// Path 使用 SFS 的 key，例如 p.spinResult.waysResult[2].hitOdds。
+type FieldError struct {
+	Path     string
+	GoType   reflect.Type
+	WireType DataType
+	Err      error
+}
+
+func (e *FieldError) Error() string {
+	var sb strings.Builder
+	sb.WriteString("sfs: field ")
+	if e.Path != "" {
+		sb.WriteString(e.Path)
+	} else {
+		sb.WriteString("<root>")
+	}
+	if e.GoType != nil {
+		fmt.Fprintf(&sb, " (%s, %s)", e.GoType, e.WireType)
+	}
+	sb.WriteString(": ")
+	sb.WriteString(e.Err.Error())
+	return sb.String()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// MultiError 汇总多个错误，UnmarshalOptions.ReportAll 模式下返回
+type MultiError struct {
+	Errors []error
+}
+
+func (e *MultiError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "sfs: %d errors:", len(e.Errors))
+	for _, err := range e.Errors {
+		sb.WriteString("\n\t")
+		sb.WriteString(err.Error())
+	}
+	return sb.String()
+}
+
+func (e *MultiError) Unwrap() []error {
+	return e.Errors
+}
+
+// appendError 追加错误，MultiError 展开为单个错误
+func appendError(errs []error, err error) []error {
+	if me, ok := err.(*MultiError); ok {
+		return append(errs, me.Errors...)
+	}
+	return append(errs, err)
+}
+
+// joinErrors 没有错误时返回 nil，只有一个错误时原样返回
+func joinErrors(errs []error) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	default:
+		return &MultiError{Errors: errs}
+	}
+}
+
+// joinPath 拼接路径，下标段（[2]）直接追加，key 段用 . 分隔
+func joinPath(prefix, seg string) string {
+	if prefix == "" {
+		return seg
+	}
+	if seg == "" {
+		return prefix
+	}
+	if strings.HasPrefix(seg, "[") {
+		return prefix + seg
+	}
+	return prefix + "." + seg
+}
+
+func indexSeg(i int) string {
+	return "[" + strconv.Itoa(i) + "]"
+}
+
+// prefixPath 为嵌套调用返回的错误补上外层路径段，普通错误包装为 FieldError
+func prefixPath(err error, seg string, goType reflect.Type, wireType DataType) error {
+	switch e := err.(type) {
+	case *FieldError:
+		e.Path = joinPath(seg, e.Path)
+		return e
+	case *DecodeError:
+		e.Path = joinPath(seg, e.Path)
+		return e
+	case *MultiError:
+		for i, err := range e.Errors {
+			e.Errors[i] = prefixPath(err, seg, goType, wireType)
+		}
+		return e
+	}
+	return &FieldError{Path: seg, GoType: goType, WireType: wireType, Err: err}
+}
+
+// truncated 将 io.EOF 类错误统一为 ErrTruncated
+func truncated(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrTruncated
+	}
+	return err
+}
+
+// wireTypeOf 返回 SFS 值对应的数据类型，无法识别时返回 NULL
+func wireTypeOf(v interface{}) DataType {
+	switch v.(type) {
+	case bool:
+		return BOOL
+	case byte:
+		return BYTE
+	case int16:
+		return SHORT
+	case int32:
+		return INT
+	case int, int64:
+		return LONG
+	case float32:
+		return FLOAT
+	case float64:
+		return DOUBLE
+	case string:
+		return UTF_STRING
+	case []bool:
+		return BOOL_ARRAY
+	case []byte:
+		return BYTE_ARRAY
+	case []int16:
+		return SHORT_ARRAY
+	case []int32:
+		return INT_ARRAY
+	case []int64:
+		return LONG_ARRAY
+	case []float32:
+		return FLOAT_ARRAY
+	case []float64:
+		return DOUBLE_ARRAY
+	case []string:
+		return UTF_STRING_ARRAY
+	case SFSArray, []interface{}:
+		return SFS_ARRAY
+	case SFSObject, map[string]interface{}:
+		return SFS_OBJECT
+	default:
+		return NULL
+	}
+}
@@ -0,0 +1,57 @@
+package sfs
+
+// WireValue 是可以直接放入 SFSObject/SFSArray 并由 Packer 编码的 Go 类型，
+// 每个类型唯一对应一种 SFS 数据类型
+type WireValue interface {
+	bool | byte | int16 | int32 | int64 | float32 | float64 | string |
+		[]bool | []byte | []int16 | []int32 | []int64 | []float32 | []float64 | []string |
+		SFSObject | SFSArray
+}
+
+// ArrayElem 是有对应定长数组类型的标量类型，[]T 编码为 BOOL_ARRAY、BYTE_ARRAY、
+// SHORT_ARRAY、INT_ARRAY、LONG_ARRAY、FLOAT_ARRAY、DOUBLE_ARRAY 或 UTF_STRING_ARRAY
+type ArrayElem interface {
+	bool | byte | int16 | int32 | int64 | float32 | float64 | string
+}
+
+// UnmarshalAs 将 SFSObject 解码为 T 类型的结构体
+func UnmarshalAs[T any](obj SFSObject) (T, error) {
+	var v T
+	err := Unmarshal(obj, &v)
+	return v, err
+}
+
+// Get 返回 key 对应的值，只有 key 存在且数据类型与 T 一致时 ok 为 true，
+// 例如 INT 只能用 Get[int32] 读取
+func Get[T WireValue](obj SFSObject, key string) (T, bool) {
+	v, ok := obj[key].(T)
+	return v, ok
+}
+
+// GetAt 返回数组中下标 i 的值，下标越界或数据类型与 T 不一致时 ok 为 false
+func GetAt[T WireValue](arr SFSArray, i int) (T, bool) {
+	if i < 0 || i >= len(arr) {
+		var zero T
+		return zero, false
+	}
+	v, ok := arr[i].(T)
+	return v, ok
+}
+
+// ArrayOf 用相同类型的标量构造类型化数组，例如 ArrayOf(int32(1), int32(2)) 编码为 INT_ARRAY，
+// 与 Marshal 编码 []int32 字段的结果相同
+func ArrayOf[T ArrayElem](items ...T) []T {
+	arr := make([]T, len(items))
+	copy(arr, items)
+	return arr
+}
+
+// SFSArrayOf 用相同类型的元素构造 SFSArray，总是编码为 SFS_ARRAY，每个元素单独带类型字节；
+// 标量需要类型化数组时使用 ArrayOf
+func SFSArrayOf[T WireValue](items ...T) SFSArray {
+	arr := make(SFSArray, len(items))
+	for i, item := range items {
+		arr[i] = item
+	}
+	return arr
+}
@@ -3,7 +3,9 @@ package sfs
 import (
 	"errors"
 	"fmt"
+	"math"
 	"reflect"
+	"strconv"
 )
 
 func Marshal(v interface{}) (SFSObject, error) {
@@ -16,46 +18,51 @@ func Marshal(v interface{}) (SFSObject, error) {
 		return nil, errors.New("only structs can be marshaled to SFSObject")
 	}
 
-	result := make(SFSObject)
-	typ := val.Type()
+	plan, err := cachedPlan(val.Type())
+	if err != nil {
+		return nil, err
+	}
+	if plan.tuple {
+		return nil, fmt.Errorf("%s embeds sfs.Tuple, use MarshalArray", val.Type())
+	}
+	return encodeObject(plan, val)
+}
 
-	for i := 0; i < val.NumField(); i++ {
-		field := typ.Field(i)
-		fieldVal := val.Field(i)
-
-		// Skip unexported fields
-		if !fieldVal.CanInterface() {
-			continue
-		}
-
-		info, err := parseTag(field)
-		if err != nil {
-			return nil, err
-		}
+// encodeObject 按字段计划将结构体编码为 SFSObject
+func encodeObject(plan *structPlan, val reflect.Value) (SFSObject, error) {
+	result := make(SFSObject, len(plan.fields))
+	for i := range plan.fields {
+		fp := &plan.fields[i]
+		fieldVal := fp.value(val)
 
 		// Skip zero value optional fields
-		if info.optional && isZero(fieldVal) {
+		if fp.omit(fieldVal) {
 			continue
 		}
 
-		sfsValue, err := convertToSFSValue(fieldVal, info.dataType)
+		sfsValue, err := fp.encode(fieldVal)
 		if err != nil {
-			return nil, fmt.Errorf("field %s: %v", field.Name, err)
+			return nil, prefixPath(err, fp.name, fieldVal.Type(), fp.dataType)
 		}
 
-		result[info.name] = sfsValue
+		result[fp.name] = sfsValue
 	}
 
 	return result, nil
 }
 
 func convertToSFSValue(val reflect.Value, dtype DataType) (interface{}, error) {
+	// 注册了编码函数的类型
+	if fn, ok := lookupEncoder(val.Type()); ok {
+		return encodeRegistered(fn, val)
+	}
+
 	// 处理指针类型
 	if val.Kind() == reflect.Ptr {
 		if val.IsNil() {
 			return nil, nil
 		}
-		val = val.Elem()
+		return convertToSFSValue(val.Elem(), dtype)
 	}
 
 	// 自动类型推断
@@ -63,22 +70,9 @@ func convertToSFSValue(val reflect.Value, dtype DataType) (interface{}, error) {
 		switch val.Kind() {
 		case reflect.Bool:
 			dtype = BOOL
-		case reflect.Int8:
-			dtype = BYTE
-		case reflect.Uint8:
-			dtype = BYTE
-		case reflect.Int16:
-			dtype = SHORT
-		case reflect.Uint16:
-			dtype = SHORT
-		case reflect.Int32:
-			dtype = INT
-		case reflect.Uint32:
-			dtype = INT
-		case reflect.Int, reflect.Int64:
-			dtype = LONG
-		case reflect.Uint, reflect.Uint64:
-			dtype = LONG
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			dtype = intWireType(val.Kind())
 		case reflect.Float32:
 			dtype = FLOAT
 		case reflect.Float64:
@@ -91,7 +85,7 @@ func convertToSFSValue(val reflect.Value, dtype DataType) (interface{}, error) {
 			}
 			return convertSliceToSFS(val, dtype)
 		case reflect.Struct:
-			return Marshal(val.Interface())
+			return marshalStruct(val)
 		case reflect.Interface:
 			// 处理 interface{} 类型
 			if val.IsNil() {
@@ -102,7 +96,7 @@ func convertToSFSValue(val reflect.Value, dtype DataType) (interface{}, error) {
 			// 处理 map 类型
 			return convertMapToSFSObject(val)
 		default:
-			return nil, fmt.Errorf("unsupported type: %s", val.Kind())
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, val.Type())
 		}
 	}
 
@@ -123,13 +117,16 @@ func convertToSFSValue(val reflect.Value, dtype DataType) (interface{}, error) {
 	case BOOL:
 		return val.Bool(), nil
 	case BYTE:
-		return byte(val.Uint()), nil
+		n, err := checkedInt(val, BYTE)
+		return byte(n), err
 	case SHORT:
-		return int16(val.Int()), nil
+		n, err := checkedInt(val, SHORT)
+		return int16(n), err
 	case INT:
-		return int32(val.Int()), nil
+		n, err := checkedInt(val, INT)
+		return int32(n), err
 	case LONG:
-		return val.Int(), nil
+		return checkedInt(val, LONG)
 	case FLOAT:
 		return float32(val.Float()), nil
 	case DOUBLE:
@@ -140,6 +137,9 @@ func convertToSFSValue(val reflect.Value, dtype DataType) (interface{}, error) {
 		if val.Kind() == reflect.Struct {
 			return Marshal(val.Interface())
 		}
+		if val.Kind() == reflect.Map {
+			return convertMapToSFSObject(val)
+		}
 		return nil, fmt.Errorf("cannot convert %s to SFS_OBJECT", val.Kind())
 	case SFS_ARRAY:
 		if val.Kind() == reflect.Slice || val.Kind() == reflect.Array {
@@ -196,7 +196,7 @@ func convertInterfaceSliceToSFS(val reflect.Value) (interface{}, error) {
 		case reflect.Map:
 			arr[i], err = convertMapToSFSObject(elem)
 		case reflect.Struct:
-			arr[i], err = Marshal(elem.Interface())
+			arr[i], err = marshalStruct(elem)
 		case reflect.Interface:
 			// 多层 interface{} 包装
 			arr[i], err = convertToSFSValue(elem, NULL)
@@ -205,7 +205,7 @@ func convertInterfaceSliceToSFS(val reflect.Value) (interface{}, error) {
 		}
 
 		if err != nil {
-			return nil, fmt.Errorf("element %d: %v", i, err)
+			return nil, prefixPath(err, indexSeg(i), elem.Type(), NULL)
 		}
 	}
 
@@ -218,48 +218,58 @@ func convertMapToSFSObject(val reflect.Value) (SFSObject, error) {
 		return nil, errors.New("value is not a map")
 	}
 
-	obj := make(SFSObject)
-	for _, key := range val.MapKeys() {
-		if key.Kind() != reflect.String {
-			return nil, errors.New("map key must be string")
-		}
-
-		mapVal := val.MapIndex(key)
-		if !mapVal.IsValid() {
-			obj[key.String()] = nil
-			continue
+	obj := make(SFSObject, val.Len())
+	iter := val.MapRange()
+	for iter.Next() {
+		key, err := formatMapKey(iter.Key())
+		if err != nil {
+			return nil, err
 		}
 
-		var err error
+		mapVal := iter.Value()
 		switch mapVal.Kind() {
 		case reflect.Interface:
 			if mapVal.IsNil() {
-				obj[key.String()] = nil
+				obj[key] = nil
 				continue
 			}
-			obj[key.String()], err = convertToSFSValue(mapVal.Elem(), NULL)
+			obj[key], err = convertToSFSValue(mapVal.Elem(), NULL)
 		case reflect.Slice, reflect.Array:
 			if mapVal.Type().Elem().Kind() == reflect.Interface {
-				obj[key.String()], err = convertInterfaceSliceToSFS(mapVal)
+				obj[key], err = convertInterfaceSliceToSFS(mapVal)
 			} else {
-				obj[key.String()], err = convertSliceToSFS(mapVal, NULL)
+				obj[key], err = convertSliceToSFS(mapVal, NULL)
 			}
 		case reflect.Map:
-			obj[key.String()], err = convertMapToSFSObject(mapVal)
+			obj[key], err = convertMapToSFSObject(mapVal)
 		case reflect.Struct:
-			obj[key.String()], err = Marshal(mapVal.Interface())
+			obj[key], err = marshalStruct(mapVal)
 		default:
-			obj[key.String()], err = convertToSFSValue(mapVal, NULL)
+			obj[key], err = convertToSFSValue(mapVal, NULL)
 		}
 
 		if err != nil {
-			return nil, fmt.Errorf("key %s: %v", key.String(), err)
+			return nil, prefixPath(err, key, mapVal.Type(), NULL)
 		}
 	}
 
 	return obj, nil
 }
 
+// formatMapKey 将 map 的 key 转换为 SFSObject 的 key，整数类型的 key 编码为十进制字符串
+func formatMapKey(key reflect.Value) (string, error) {
+	switch {
+	case key.Kind() == reflect.String:
+		return key.String(), nil
+	case isIntKind(key.Kind()):
+		return strconv.FormatInt(key.Int(), 10), nil
+	case isUintKind(key.Kind()):
+		return strconv.FormatUint(key.Uint(), 10), nil
+	default:
+		return "", fmt.Errorf("unsupported map key type: %s", key.Type())
+	}
+}
+
 func convertSliceToSFS(val reflect.Value, dtype DataType) (interface{}, error) {
 	if val.Kind() != reflect.Slice && val.Kind() != reflect.Array {
 		return nil, errors.New("value is not a slice or array")
@@ -269,6 +279,9 @@ func convertSliceToSFS(val reflect.Value, dtype DataType) (interface{}, error) {
 	if length == 0 {
 		return nil, nil
 	}
+	if fn, ok := lookupEncoder(val.Type().Elem()); ok && primitiveArrayElems[dtype] != nil {
+		return encodeRegisteredArray(val, fn, dtype)
+	}
 
 	// 根据指定的SFS数据类型处理
 	switch dtype {
@@ -284,7 +297,7 @@ func convertSliceToSFS(val reflect.Value, dtype DataType) (interface{}, error) {
 		return arr, nil
 
 	case BYTE_ARRAY:
-		if val.Type().Elem().Kind() == reflect.Uint8 {
+		if val.Kind() == reflect.Slice && val.Type().Elem().Kind() == reflect.Uint8 {
 			return val.Bytes(), nil
 		}
 		arr := make([]byte, length)
@@ -293,7 +306,11 @@ func convertSliceToSFS(val reflect.Value, dtype DataType) (interface{}, error) {
 			if elem.Kind() == reflect.Ptr && elem.IsNil() {
 				continue
 			}
-			arr[i] = byte(elem.Uint())
+			n, err := checkedInt(elem, BYTE)
+			if err != nil {
+				return nil, prefixPath(err, indexSeg(i), elem.Type(), BYTE)
+			}
+			arr[i] = byte(n)
 		}
 		return arr, nil
 
@@ -304,7 +321,11 @@ func convertSliceToSFS(val reflect.Value, dtype DataType) (interface{}, error) {
 			if elem.Kind() == reflect.Ptr && elem.IsNil() {
 				continue
 			}
-			arr[i] = int16(elem.Int())
+			n, err := checkedInt(elem, SHORT)
+			if err != nil {
+				return nil, prefixPath(err, indexSeg(i), elem.Type(), SHORT)
+			}
+			arr[i] = int16(n)
 		}
 		return arr, nil
 
@@ -315,7 +336,11 @@ func convertSliceToSFS(val reflect.Value, dtype DataType) (interface{}, error) {
 			if elem.Kind() == reflect.Ptr && elem.IsNil() {
 				continue
 			}
-			arr[i] = int32(elem.Int())
+			n, err := checkedInt(elem, INT)
+			if err != nil {
+				return nil, prefixPath(err, indexSeg(i), elem.Type(), INT)
+			}
+			arr[i] = int32(n)
 		}
 		return arr, nil
 
@@ -326,7 +351,11 @@ func convertSliceToSFS(val reflect.Value, dtype DataType) (interface{}, error) {
 			if elem.Kind() == reflect.Ptr && elem.IsNil() {
 				continue
 			}
-			arr[i] = elem.Int()
+			n, err := checkedInt(elem, LONG)
+			if err != nil {
+				return nil, prefixPath(err, indexSeg(i), elem.Type(), LONG)
+			}
+			arr[i] = n
 		}
 		return arr, nil
 
@@ -374,7 +403,7 @@ func convertSliceToSFS(val reflect.Value, dtype DataType) (interface{}, error) {
 			var err error
 			arr[i], err = convertToSFSValue(elem, NULL)
 			if err != nil {
-				return nil, err
+				return nil, prefixPath(err, indexSeg(i), elem.Type(), NULL)
 			}
 		}
 		return arr, nil
@@ -382,24 +411,27 @@ func convertSliceToSFS(val reflect.Value, dtype DataType) (interface{}, error) {
 	default:
 		// 没有指定具体数组类型，尝试自动推断
 		elemType := val.Type().Elem()
+		if _, ok := lookupEncoder(elemType); ok {
+			// 数据类型固定的注册类型（如枚举）使用基本类型数组
+			if dt, ok := lookupWireType(elemType); ok {
+				return convertSliceToSFS(val, arrayTypeOf(dt))
+			}
+			return convertSliceToSFS(val, SFS_ARRAY)
+		}
 		switch elemType.Kind() {
 		case reflect.Bool:
 			return convertSliceToSFS(val, BOOL_ARRAY)
-		case reflect.Uint8:
-			return convertSliceToSFS(val, BYTE_ARRAY)
-		case reflect.Int16:
-			return convertSliceToSFS(val, SHORT_ARRAY)
-		case reflect.Int32:
-			return convertSliceToSFS(val, INT_ARRAY)
-		case reflect.Int, reflect.Int64:
-			return convertSliceToSFS(val, LONG_ARRAY)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return convertSliceToSFS(val, arrayTypeOf(intWireType(elemType.Kind())))
 		case reflect.Float32:
 			return convertSliceToSFS(val, FLOAT_ARRAY)
 		case reflect.Float64:
 			return convertSliceToSFS(val, DOUBLE_ARRAY)
 		case reflect.String:
 			return convertSliceToSFS(val, UTF_STRING_ARRAY)
-		case reflect.Struct, reflect.Interface, reflect.Ptr:
+		case reflect.Struct, reflect.Interface, reflect.Ptr,
+			reflect.Slice, reflect.Array, reflect.Map:
 			return convertSliceToSFS(val, SFS_ARRAY)
 		default:
 			return nil, fmt.Errorf("unsupported slice element type: %s", elemType.Kind())
@@ -407,6 +439,83 @@ func convertSliceToSFS(val reflect.Value, dtype DataType) (interface{}, error) {
 	}
 }
 
+// intWireType 返回整数类型默认映射的 SFS 类型。
+// SFS 没有无符号整数，无符号类型提升为下一个更宽的有符号类型：
+// uint8 -> BYTE, uint16 -> INT, uint32/uint64/uint -> LONG（超出 int64 范围时报错）
+func intWireType(k reflect.Kind) DataType {
+	switch k {
+	case reflect.Int8, reflect.Uint8:
+		return BYTE
+	case reflect.Int16:
+		return SHORT
+	case reflect.Int32, reflect.Uint16:
+		return INT
+	default:
+		return LONG
+	}
+}
+
+// arrayTypeOf 返回标量类型对应的数组类型
+func arrayTypeOf(dtype DataType) DataType {
+	switch dtype {
+	case BOOL:
+		return BOOL_ARRAY
+	case BYTE:
+		return BYTE_ARRAY
+	case SHORT:
+		return SHORT_ARRAY
+	case INT:
+		return INT_ARRAY
+	case LONG:
+		return LONG_ARRAY
+	case FLOAT:
+		return FLOAT_ARRAY
+	case DOUBLE:
+		return DOUBLE_ARRAY
+	case UTF_STRING:
+		return UTF_STRING_ARRAY
+	default:
+		return SFS_ARRAY
+	}
+}
+
+// checkedInt 读取有符号或无符号整数值，并检查是否在 dtype 的取值范围内。
+// BYTE 按无符号 0..255 处理，int8 按补码存储（与 Java 的 byte 一致）。
+func checkedInt(val reflect.Value, dtype DataType) (int64, error) {
+	var n int64
+	switch {
+	case isIntKind(val.Kind()):
+		n = val.Int()
+		if dtype == BYTE && val.Kind() == reflect.Int8 {
+			return int64(byte(n)), nil
+		}
+	case isUintKind(val.Kind()):
+		u := val.Uint()
+		if u > math.MaxInt64 {
+			return 0, fmt.Errorf("value %d of type %s overflows %s", u, val.Type(), dtype)
+		}
+		n = int64(u)
+	default:
+		return 0, fmt.Errorf("cannot convert %s to %s", val.Type(), dtype)
+	}
+
+	var lo, hi int64
+	switch dtype {
+	case BYTE:
+		lo, hi = 0, math.MaxUint8
+	case SHORT:
+		lo, hi = math.MinInt16, math.MaxInt16
+	case INT:
+		lo, hi = math.MinInt32, math.MaxInt32
+	default:
+		return n, nil
+	}
+	if n < lo || n > hi {
+		return 0, fmt.Errorf("value %d of type %s overflows %s", n, val.Type(), dtype)
+	}
+	return n, nil
+}
+
 func isZero(v reflect.Value) bool {
 	switch v.Kind() {
 	case reflect.Bool:
@@ -0,0 +1,336 @@
+package sfs
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type badTags struct {
+	A int32  `sfs:"a"`
+	B string `sfs:"b,type=INT"`
+	C int32  `sfs:"a"`
+	D int32  `sfs:"d,omitempty"`
+	E int32  `sfs:"e,type=NUMBER"`
+}
+
+func TestPrecompile(t *testing.T) {
+	if err := Precompile(Respond{}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := Precompile(&struct {
+		Inner []badTags `sfs:"inner"`
+	}{})
+	if err == nil {
+		t.Fatal("expected tag errors")
+	}
+	for _, want := range []string{"badTags.B", "badTags.C", "badTags.D", "badTags.E"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+
+	// 未知选项（如 encoding/json 的 omitempty）在 Marshal/Unmarshal 时被忽略，只由 Precompile 报告
+	type withUnknown struct {
+		X int32 `sfs:"x,omitempty"`
+	}
+	obj, err := Marshal(withUnknown{X: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out withUnknown
+	if err := Unmarshal(obj, &out); err != nil || out.X != 1 {
+		t.Fatalf("Unmarshal = %+v, %v", out, err)
+	}
+	if err := Precompile(withUnknown{}); err == nil || !strings.Contains(err.Error(), `"omitempty"`) {
+		t.Fatalf("expected unknown option error, got %v", err)
+	}
+}
+
+// roundTrip 依次执行 Marshal、Pack、Unpack 和 Unmarshal（解码到 out 指向的值），
+// 检查结果与 in 相同
+func roundTrip(t *testing.T, in, out interface{}) {
+	t.Helper()
+	obj, err := Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	data, err := Pack(obj, PackOptions{})
+	if err != nil {
+		t.Fatalf("Pack: %v", err)
+	}
+	v, err := Unpack(data, UnpackOptions{})
+	if err != nil {
+		t.Fatalf("Unpack: %v", err)
+	}
+	if err := Unmarshal(v.(SFSObject), out); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if got := reflect.ValueOf(out).Elem().Interface(); !reflect.DeepEqual(got, in) {
+		t.Fatalf("round trip mismatch:\n got: %+v\nwant: %+v", got, in)
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	rsp, err := Marshal(Respond{
+		Code: 200,
+		Data: Data{Index: 1},
+		Msg:  "success",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var data Respond
+	if err := Unmarshal(rsp, &data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Code != 200 || data.Data.Index != 1 || data.Msg != "success" {
+		t.Fatalf("unexpected result: %+v", data)
+	}
+}
+
+type mapHolder struct {
+	Scores  map[string]int32  `sfs:"scores"`
+	Players map[string]Data   `sfs:"players,type=SFS_OBJECT"`
+	ByID    map[int64]string  `sfs:"byId"`
+	Extra   map[string]any    `sfs:"extra"`
+	Levels  map[uint8][]int32 `sfs:"levels,optional"`
+}
+
+func TestMapRoundTrip(t *testing.T) {
+	in := mapHolder{
+		Scores:  map[string]int32{"a": 1, "b": 2},
+		Players: map[string]Data{"p1": {Index: 7}},
+		ByID:    map[int64]string{-3: "x", 42: "y"},
+		Extra:   map[string]any{"n": int32(5), "s": "str"},
+		Levels:  map[uint8][]int32{1: {1, 2, 3}},
+	}
+	obj, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := obj["byId"].(SFSObject)["-3"]; !ok {
+		t.Fatalf("integer key not encoded as decimal string: %v", obj["byId"])
+	}
+
+	var out mapHolder
+	if err := Unmarshal(obj, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("round trip mismatch:\n in: %+v\nout: %+v", in, out)
+	}
+
+	bad := SFSObject{"scores": SFSObject{}, "players": SFSObject{}, "extra": SFSObject{},
+		"byId": SFSObject{"notAnInt": "x"}}
+	if err := Unmarshal(bad, &out); err == nil {
+		t.Fatal("expected error for non-numeric key")
+	}
+}
+
+type unsignedHolder struct {
+	U8     uint8    `sfs:"u8"`
+	I8     int8     `sfs:"i8"`
+	U16    uint16   `sfs:"u16"`
+	U32    uint32   `sfs:"u32"`
+	U64    uint64   `sfs:"u64"`
+	Short  uint16   `sfs:"short,type=SHORT"`
+	U16Arr []uint16 `sfs:"u16Arr"`
+	U32Arr []uint32 `sfs:"u32Arr"`
+}
+
+func TestUnsignedMapping(t *testing.T) {
+	in := unsignedHolder{
+		U8: 200, I8: -5, U16: 65535, U32: 4000000000, U64: 1 << 40, Short: 300,
+		U16Arr: []uint16{1, 65535}, U32Arr: []uint32{4000000000},
+	}
+	obj, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := obj["u16"].(int32); !ok {
+		t.Errorf("uint16 should widen to INT, got %T", obj["u16"])
+	}
+	if _, ok := obj["u32"].(int64); !ok {
+		t.Errorf("uint32 should widen to LONG, got %T", obj["u32"])
+	}
+	if _, ok := obj["u32Arr"].([]int64); !ok {
+		t.Errorf("[]uint32 should widen to LONG_ARRAY, got %T", obj["u32Arr"])
+	}
+
+	var out unsignedHolder
+	if err := Unmarshal(obj, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("round trip mismatch:\n in: %+v\nout: %+v", in, out)
+	}
+
+	if _, err := Marshal(unsignedHolder{U64: 1 << 63}); err == nil {
+		t.Error("expected overflow error for uint64 above MaxInt64")
+	}
+	if _, err := Marshal(unsignedHolder{Short: 40000}); err == nil {
+		t.Error("expected overflow error for uint16 tagged as SHORT")
+	}
+	obj["u16"] = int32(-1)
+	if err := Unmarshal(obj, &out); err == nil {
+		t.Error("expected overflow error for negative INT into uint16")
+	}
+}
+
+type coins int64
+
+// cents 以字符串 "12.34" 的形式传输
+type cents struct{ v int64 }
+
+type wallet struct {
+	Balance cents   `sfs:"balance"`
+	History []cents `sfs:"history"`
+}
+
+func TestRegistry(t *testing.T) {
+	centsType := reflect.TypeOf(cents{})
+	RegisterEncoder(centsType, func(v reflect.Value) (interface{}, error) {
+		c := v.Interface().(cents)
+		return fmt.Sprintf("%d.%02d", c.v/100, c.v%100), nil
+	})
+	RegisterDecoder(centsType, func(sfsValue interface{}, v reflect.Value) error {
+		s, ok := sfsValue.(string)
+		if !ok {
+			return fmt.Errorf("expected string, got %T", sfsValue)
+		}
+		var whole, frac int64
+		if _, err := fmt.Sscanf(s, "%d.%d", &whole, &frac); err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(cents{whole*100 + frac}))
+		return nil
+	})
+
+	obj := SFSObject{
+		"coins": coins(5),
+		"i8":    int8(-1),
+		"u16":   uint16(7),
+		"rows":  []SFSObject{{"a": int32(1)}},
+		"maps":  []map[string]interface{}{{"b": "x"}},
+		"empty": []SFSObject{},
+		"s":     struct{ A int32 }{1},
+		"money": cents{1234},
+	}
+	data, err := Pack(obj, PackOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := Size(obj); err != nil || n != len(data) {
+		t.Fatalf("Size = %d, %v; Pack produced %d bytes", n, err, len(data))
+	}
+	v, err := Unpack(data, UnpackOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := SFSObject{
+		"coins": int64(5),
+		"i8":    byte(255),
+		"u16":   int32(7),
+		"rows":  SFSArray{SFSObject{"a": int32(1)}},
+		"maps":  SFSArray{SFSObject{"b": "x"}},
+		"empty": SFSArray{},
+		"s":     SFSObject{"A": int32(1)},
+		"money": "12.34",
+	}
+	if !reflect.DeepEqual(v, want) {
+		t.Fatalf("unexpected result:\n got: %v\nwant: %v", v, want)
+	}
+
+	in := wallet{Balance: cents{1050}, History: []cents{{1}, {250}}}
+	m, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out wallet
+	if err := Unmarshal(m, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("round trip mismatch: %+v", out)
+	}
+}
+
+type spinEntity struct {
+	Balance float64 `json:"balance"`
+	GameSeq int64   `json:"gameSeq"`
+}
+
+type payloadHolder struct {
+	Entity spinEntity         `sfs:"entity,json"`
+	Doc    map[string]int     `sfs:"doc,json,type=UTF_STRING"`
+	Blob   *Data              `sfs:"blob,sfsbin"`
+	Extra  map[string]float64 `sfs:"extra,sfsbin,optional"`
+}
+
+func TestPayloadTags(t *testing.T) {
+	in := payloadHolder{
+		Entity: spinEntity{Balance: 800.4, GameSeq: 7499736444769},
+		Doc:    map[string]int{"a": 1},
+		Blob:   &Data{Index: 3},
+	}
+	obj, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, ok := obj["entity"].([]byte); !ok || string(b) != `{"balance":800.4,"gameSeq":7499736444769}` {
+		t.Fatalf("unexpected entity: %#v", obj["entity"])
+	}
+	if s, ok := obj["doc"].(string); !ok || s != `{"a":1}` {
+		t.Fatalf("unexpected doc: %#v", obj["doc"])
+	}
+	if _, ok := obj["extra"]; ok {
+		t.Fatal("optional empty sfsbin field should be omitted")
+	}
+
+	roundTrip(t, in, &payloadHolder{})
+
+	// 解码替换字段原有的值，而不是合并到里面
+	extra, err := Marshal(payloadHolder{Blob: &Data{}, Extra: map[string]float64{"y": 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj["extra"] = extra["extra"]
+	out := payloadHolder{Doc: map[string]int{"b": 2}, Extra: map[string]float64{"x": 1}}
+	if err := Unmarshal(obj, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out.Doc, in.Doc) {
+		t.Errorf("Doc = %v, want %v", out.Doc, in.Doc)
+	}
+	if want := map[string]float64{"y": 2}; !reflect.DeepEqual(out.Extra, want) {
+		t.Errorf("Extra = %v, want %v", out.Extra, want)
+	}
+
+	// sfsbin 对象之后多余的字节
+	obj["blob"] = append(obj["blob"].([]byte), 0)
+	var de *DecodeError
+	if err := Unmarshal(obj, &out); !errors.As(err, &de) {
+		t.Fatalf("expected DecodeError for trailing data, got %v", err)
+	}
+
+	type badPayload struct {
+		A string `sfs:"a,json,sfsbin"`
+		B Data   `sfs:"b,sfsbin,type=UTF_STRING"`
+		C string `sfs:"c,sfsbin"`
+		D Data   `sfs:"d,json,type=INT"`
+	}
+	err = Precompile(badPayload{})
+	if err == nil {
+		t.Fatal("expected tag errors")
+	}
+	for _, field := range []string{".A:", ".B:", ".C:", ".D:"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("expected error for %s, got %v", field, err)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package sfs
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Optional 区分 key 不存在和 key 存在（值可以是零值）。
+// Marshal 在 Set 为 false 时省略 key，为 true 时总是写入 Value；
+// Unmarshal 在 key 存在时将 Set 置为 true，key 不存在不是错误。
+// 用于只修改请求中出现的字段的部分更新。
+type Optional[T any] struct {
+	Value T
+	Set   bool
+}
+
+// Some 返回 Set 为 true 的 Optional
+func Some[T any](v T) Optional[T] {
+	return Optional[T]{Value: v, Set: true}
+}
+
+// Get 返回 Value 和 Set
+func (o Optional[T]) Get() (T, bool) {
+	return o.Value, o.Set
+}
+
+// Nullable 区分 NULL 和零值。Marshal 在 Valid 为 false 时写入 NULL；
+// Unmarshal 遇到 NULL 时将 Valid 置为 false。
+// Optional[Nullable[T]] 可以区分 key 不存在、NULL 和零值三种情况。
+type Nullable[T any] struct {
+	Value T
+	Valid bool
+}
+
+// NullableOf 返回 Valid 为 true 的 Nullable
+func NullableOf[T any](v T) Nullable[T] {
+	return Nullable[T]{Value: v, Valid: true}
+}
+
+// Get 返回 Value 和 Valid
+func (n Nullable[T]) Get() (T, bool) {
+	return n.Value, n.Valid
+}
+
+func (Optional[T]) wrapKind() wrapKind { return wrapOptional }
+func (Nullable[T]) wrapKind() wrapKind { return wrapNullable }
+
+type wrapKind byte
+
+const (
+	wrapNone wrapKind = iota
+	wrapOptional
+	wrapNullable
+)
+
+// wrapper 由 Optional 和 Nullable 实现，两者的第 0 个字段是 Value，第 1 个字段是 Set/Valid
+type wrapper interface {
+	wrapKind() wrapKind
+}
+
+var wrapperType = reflect.TypeOf((*wrapper)(nil)).Elem()
+
+// wrapKindOf 返回 t 是 Optional、Nullable 还是都不是
+func wrapKindOf(t reflect.Type) wrapKind {
+	if t.Kind() != reflect.Struct || !t.Implements(wrapperType) {
+		return wrapNone
+	}
+	return reflect.Zero(t).Interface().(wrapper).wrapKind()
+}
+
+// unwrapField 去掉字段类型外层的 Optional 和 Nullable（最多各一层，Optional 在外），
+// 返回 Value 的类型
+func unwrapField(t reflect.Type) (inner reflect.Type, optional, nullable bool, err error) {
+	if wrapKindOf(t) == wrapOptional {
+		optional = true
+		t = t.Field(0).Type
+	}
+	if wrapKindOf(t) == wrapNullable {
+		nullable = true
+		t = t.Field(0).Type
+	}
+	if wrapKindOf(t) != wrapNone {
+		return nil, false, false, fmt.Errorf("%s cannot be nested here, use Optional[Nullable[T]]", t)
+	}
+	return t, optional, nullable, nil
+}
+
+// wrapEncode 为 Optional/Nullable 字段包装编码函数：Nullable 的 Valid 为 false 时编码为 NULL
+func wrapEncode(encode func(reflect.Value) (interface{}, error), optional, nullable bool) func(reflect.Value) (interface{}, error) {
+	return func(val reflect.Value) (interface{}, error) {
+		if optional {
+			val = val.Field(0)
+		}
+		if nullable {
+			if !val.Field(1).Bool() {
+				return nil, nil
+			}
+			val = val.Field(0)
+		}
+		return encode(val)
+	}
+}
+
+// wrapDecode 为 Optional/Nullable 字段包装解码函数：设置 Set，NULL 时 Valid 为 false
+func wrapDecode(decode func(*decoder, reflect.Value, interface{}) error, optional, nullable bool) func(*decoder, reflect.Value, interface{}) error {
+	return func(d *decoder, field reflect.Value, sfsValue interface{}) error {
+		if optional {
+			field.Field(1).SetBool(true)
+			field = field.Field(0)
+		}
+		if nullable {
+			if sfsValue == nil {
+				field.Set(reflect.Zero(field.Type()))
+				return nil
+			}
+			field.Field(1).SetBool(true)
+			field = field.Field(0)
+		}
+		return decode(d, field, sfsValue)
+	}
+}
+
+// encodeWrapped 编码不是结构体字段的 Optional/Nullable（如切片元素和 map 值），
+// Set 或 Valid 为 false 时编码为 NULL
+func encodeWrapped(val reflect.Value, dtype DataType) (interface{}, error) {
+	if !val.Field(1).Bool() {
+		return nil, nil
+	}
+	return convertToSFSValue(val.Field(0), dtype)
+}
+
+// decodeWrapped 是 encodeWrapped 的逆过程
+func (d *decoder) decodeWrapped(field reflect.Value, sfsValue interface{}, dtype DataType) error {
+	if sfsValue == nil {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+	field.Field(1).SetBool(true)
+	return d.convertFromSFSValue(field.Field(0), sfsValue, dtype)
+}
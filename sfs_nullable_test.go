@@ -0,0 +1,102 @@
+package sfs
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type profileUpdate struct {
+	UserID   int32                      `sfs:"userId"`
+	Nickname Optional[string]           `sfs:"nickname"`
+	Avatar   Optional[Nullable[string]] `sfs:"avatar"`
+	Level    Nullable[int16]            `sfs:"level"`
+	Balance  Optional[Decimal]          `sfs:"balance,decimal=2"`
+	Scores   []Nullable[int32]          `sfs:"scores,optional"`
+}
+
+func TestOptionalNullable(t *testing.T) {
+	in := profileUpdate{
+		UserID:   1,
+		Nickname: Some(""),
+		Avatar:   Some(Nullable[string]{}),
+		Balance:  Some(mustDecimal(150, 2)),
+		Scores:   []Nullable[int32]{NullableOf[int32](0), {}, NullableOf[int32](7)},
+	}
+	obj, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := SFSObject{
+		"userId":   int32(1),
+		"nickname": "",
+		"avatar":   nil,
+		"level":    nil,
+		"balance":  int64(150),
+		"scores":   SFSArray{int32(0), nil, int32(7)},
+	}
+	if !reflect.DeepEqual(obj, want) {
+		t.Fatalf("Marshal = %v, want %v", obj, want)
+	}
+
+	// NULL 经过 Pack/Unpack 保留
+	data, err := Pack(obj, PackOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	unpacked, err := Unpack(data, UnpackOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(unpacked, want) {
+		t.Fatalf("Unpack = %v, want %v", unpacked, want)
+	}
+
+	roundTrip(t, in, &profileUpdate{})
+
+	// key 存在且为 NULL：Optional 已设置，内部 Nullable 无效
+	var out profileUpdate
+	if err := Unmarshal(SFSObject{"userId": int32(3), "avatar": nil, "level": nil}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !out.Avatar.Set || out.Avatar.Value.Valid {
+		t.Errorf("Avatar = %+v; want Set with a NULL value", out.Avatar)
+	}
+	if out.Level.Valid {
+		t.Errorf("Level = %+v; want NULL", out.Level)
+	}
+	roundTrip(t, out, &profileUpdate{})
+
+	// 不存在、NULL 和零值
+	out = profileUpdate{}
+	if err := Unmarshal(SFSObject{"userId": int32(2), "level": int16(0)}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Nickname.Set || out.Avatar.Set || out.Balance.Set {
+		t.Errorf("absent keys should leave Set false: %+v", out)
+	}
+	if level, ok := out.Level.Get(); !ok || level != 0 {
+		t.Errorf("Level = %v, %v; want 0, true", level, ok)
+	}
+	obj, err = Marshal(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := obj["nickname"]; ok {
+		t.Error("unset Optional should be omitted")
+	}
+
+	// Nullable 不是 optional，key 不存在时报错
+	err = Unmarshal(SFSObject{"userId": int32(2)}, &out)
+	if !errors.Is(err, ErrMissingField) {
+		t.Fatalf("expected ErrMissingField for level, got %v", err)
+	}
+
+	type badWrap struct {
+		A Nullable[Optional[int32]] `sfs:"a"`
+		B Optional[int32]           `sfs:"b,type=UTF_STRING"`
+	}
+	if err := Precompile(badWrap{}); err == nil {
+		t.Fatal("expected errors for badWrap")
+	}
+}
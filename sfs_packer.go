@@ -4,352 +4,376 @@ import (
 	"bytes"
 	"compress/zlib"
 	"encoding/binary"
-	"errors"
 	"fmt"
+	"io"
 	"math"
+	"reflect"
+	"sync"
+	"sync/atomic"
 )
 
-type Packer struct {
-	buf *bytes.Buffer
-}
+// maxPooledBuffer 是放回池中的缓冲区的最大容量，避免偶尔的大消息长期占用内存
+const maxPooledBuffer = 1 << 20
 
-func NewPacker() *Packer {
-	return &Packer{buf: new(bytes.Buffer)}
-}
+var bufferPool = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
 
-func (p *Packer) Pack(data SFSObject, compress bool) ([]byte, error) {
-	// First encode the SFSObject to binary
-	if err := p.encodeSFSObject(data); err != nil {
-		return nil, err
-	}
+var zlibWriterPool = sync.Pool{New: func() interface{} { return zlib.NewWriter(nil) }}
 
-	dataBytes := p.buf.Bytes()
-	p.buf = new(bytes.Buffer) // Reset buffer
+var zlibReaderPool sync.Pool
 
-	// Set flags in first byte
-	var firstByte byte
-	if compress {
-		firstByte |= 32 // Set compression flag
-	}
-
-	// Determine if we need 4-byte length
-	dataLength := len(dataBytes)
-	if dataLength > math.MaxUint16 {
-		firstByte |= 8 // Set 4-byte length flag
-	}
+var slicePool = sync.Pool{New: func() interface{} { return new([]byte) }}
 
-	// Write first byte
-	if err := p.buf.WriteByte(firstByte); err != nil {
-		return nil, err
-	}
-
-	// Write length
-	if (firstByte & 8) > 0 {
-		if err := binary.Write(p.buf, binary.BigEndian, uint32(dataLength)); err != nil {
-			return nil, err
-		}
-	} else {
-		if dataLength > math.MaxUint16 {
-			return nil, errors.New("data too large for 2-byte length")
-		}
-		if err := binary.Write(p.buf, binary.BigEndian, uint16(dataLength)); err != nil {
-			return nil, err
-		}
-	}
-
-	// Compress if needed
-	if compress {
-		var compressed bytes.Buffer
-		w := zlib.NewWriter(&compressed)
-		if _, err := w.Write(dataBytes); err != nil {
-			w.Close()
-			return nil, err
-		}
-		w.Close()
-		dataBytes = compressed.Bytes()
-	}
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
 
-	// Write data
-	if _, err := p.buf.Write(dataBytes); err != nil {
-		return nil, err
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() <= maxPooledBuffer {
+		bufferPool.Put(buf)
 	}
+}
 
-	return p.buf.Bytes(), nil
+func getSlice() *[]byte {
+	return slicePool.Get().(*[]byte)
 }
 
-func (p *Packer) encodeSFSObject(obj SFSObject) error {
-	if err := p.buf.WriteByte(byte(SFS_OBJECT)); err != nil {
-		return err
+func putSlice(b *[]byte) {
+	if cap(*b) <= maxPooledBuffer {
+		*b = (*b)[:0]
+		slicePool.Put(b)
 	}
+}
 
-	// Write number of key-value pairs
-	if err := binary.Write(p.buf, binary.BigEndian, uint16(len(obj))); err != nil {
+// deflate 使用池中的 zlib writer 将 data 压缩后写入 dst
+func deflate(dst *bytes.Buffer, data []byte) error {
+	w := zlibWriterPool.Get().(*zlib.Writer)
+	defer zlibWriterPool.Put(w)
+	w.Reset(dst)
+	if _, err := w.Write(data); err != nil {
 		return err
 	}
+	return w.Close()
+}
 
-	// Write each key-value pair
-	for key, value := range obj {
-		// Write key length (UTF-STRING)
-		keyBytes := []byte(key)
-		if len(keyBytes) > math.MaxUint16 {
-			return errors.New("key too long")
-		}
-		if err := binary.Write(p.buf, binary.BigEndian, uint16(len(keyBytes))); err != nil {
-			return err
-		}
-		// Write key
-		if _, err := p.buf.Write(keyBytes); err != nil {
+// inflate 使用池中的 zlib reader 将 data 解压后写入 dst，
+// maxSize 大于 0 时解压后的数据超过 maxSize 字节返回 ErrLimitExceeded
+func inflate(dst *bytes.Buffer, data []byte, maxSize int) error {
+	src := bytes.NewReader(data)
+	var r io.ReadCloser
+	if pooled, ok := zlibReaderPool.Get().(io.ReadCloser); ok {
+		if err := pooled.(zlib.Resetter).Reset(src, nil); err != nil {
 			return err
 		}
-		// Write value
-		if err := p.encodeValue(value); err != nil {
+		r = pooled
+	} else {
+		var err error
+		if r, err = zlib.NewReader(src); err != nil {
 			return err
 		}
 	}
-	return nil
-}
+	defer zlibReaderPool.Put(r)
 
-func (p *Packer) encodeValue(value interface{}) error {
-	if value == nil {
-		return p.encodeNull()
+	if maxSize <= 0 {
+		_, err := dst.ReadFrom(r)
+		return err
 	}
-
-	switch v := value.(type) {
-	case bool:
-		return p.encodeBool(v)
-	case byte:
-		return p.encodeByte(v)
-	case int16:
-		return p.encodeShort(v)
-	case int32:
-		return p.encodeInt(v)
-	case int:
-		return p.encodeInt(int32(v))
-	case int64:
-		return p.encodeLong(v)
-	case float32:
-		return p.encodeFloat(v)
-	case float64:
-		return p.encodeDouble(v)
-	case string:
-		return p.encodeUtfString(v)
-	case []bool:
-		return p.encodeBoolArray(v)
-	case []byte:
-		return p.encodeByteArray(v)
-	case []int16:
-		return p.encodeShortArray(v)
-	case []int32:
-		return p.encodeIntArray(v)
-	case []int64:
-		return p.encodeLongArray(v)
-	case []float32:
-		return p.encodeFloatArray(v)
-	case []float64:
-		return p.encodeDoubleArray(v)
-	case []string:
-		return p.encodeUtfStringArray(v)
-	case map[string]interface{}:
-		// 将普通map转换为SFSObject
-		sfsObj := make(SFSObject)
-		for k, val := range v {
-			sfsObj[k] = val
-		}
-		return p.encodeSFSObject(sfsObj)
-	case SFSObject:
-		return p.encodeSFSObject(v)
-	case SFSArray:
-		return p.encodeSFSArray(v)
-	default:
-		return fmt.Errorf("unsupported type: %T", value)
+	n, err := dst.ReadFrom(io.LimitReader(r, int64(maxSize)+1))
+	if err == nil && n > int64(maxSize) {
+		return fmt.Errorf("%w: decompressed size exceeds %d", ErrLimitExceeded, maxSize)
 	}
+	return err
 }
 
-func (p *Packer) encodeNull() error {
-	return p.buf.WriteByte(byte(NULL))
+// PackOptions 控制 Pack 的编码方式
+type PackOptions struct {
+	// Compress 使用 zlib 压缩对象数据
+	Compress bool
+	// Strings 是 key、UTF_STRING 和 UTF_STRING_ARRAY 的编码方式
+	Strings StringEncoding
 }
 
-func (p *Packer) encodeBool(v bool) error {
-	if err := p.buf.WriteByte(byte(BOOL)); err != nil {
-		return err
-	}
-	var val byte
-	if v {
-		val = 1
-	}
-	return p.buf.WriteByte(val)
-}
+// Pack 编码 obj 并返回新分配的完整数据包，可以被多个 goroutine 同时调用
+func Pack(obj SFSObject, opts PackOptions) ([]byte, error) {
+	scratch := getSlice()
+	defer putSlice(scratch)
 
-func (p *Packer) encodeByte(v byte) error {
-	if err := p.buf.WriteByte(byte(BYTE)); err != nil {
-		return err
+	enc := encoder{modifiedUTF8: opts.Strings == ModifiedUTF8}
+	body, err := enc.appendSFSObject((*scratch)[:0], obj)
+	if err != nil {
+		return nil, err
 	}
-	return p.buf.WriteByte(v)
+	*scratch = body
+	return appendPacket(nil, body, opts.Compress)
 }
 
-func (p *Packer) encodeShort(v int16) error {
-	if err := p.buf.WriteByte(byte(SHORT)); err != nil {
-		return err
-	}
-	return binary.Write(p.buf, binary.BigEndian, v)
+// Packer 复用内部的编码缓冲区，适合在单个 goroutine 中连续打包。
+// 同一个 Packer 不能被多个 goroutine 同时使用，否则返回 ErrConcurrentUse；
+// 需要并发时使用包级别的 Pack。
+type Packer struct {
+	buf  []byte // 复用的对象数据缓冲区
+	enc  encoder
+	busy atomic.Bool
 }
 
-func (p *Packer) encodeInt(v int32) error {
-	if err := p.buf.WriteByte(byte(INT)); err != nil {
-		return err
-	}
-	return binary.Write(p.buf, binary.BigEndian, v)
+func NewPacker() *Packer {
+	return &Packer{}
 }
 
-func (p *Packer) encodeLong(v int64) error {
-	if err := p.buf.WriteByte(byte(LONG)); err != nil {
-		return err
+// SetStrings 设置 key、UTF_STRING 和 UTF_STRING_ARRAY 的编码方式，默认为 StandardUTF8；
+// 与 Pack 同时调用时返回 ErrConcurrentUse
+func (p *Packer) SetStrings(enc StringEncoding) error {
+	if !p.busy.CompareAndSwap(false, true) {
+		return ErrConcurrentUse
 	}
-	return binary.Write(p.buf, binary.BigEndian, v)
+	defer p.busy.Store(false)
+	p.enc = encoder{modifiedUTF8: enc == ModifiedUTF8}
+	return nil
 }
 
-func (p *Packer) encodeFloat(v float32) error {
-	if err := p.buf.WriteByte(byte(FLOAT)); err != nil {
-		return err
-	}
-	return binary.Write(p.buf, binary.BigEndian, v)
+// Pack 编码 data 并返回完整的数据包。
+// 返回的切片是新分配的，不引用 Packer 的内部状态，之后的调用不会修改它。
+func (p *Packer) Pack(data SFSObject, compress bool) ([]byte, error) {
+	return p.AppendPack(nil, data, compress)
 }
 
-func (p *Packer) encodeDouble(v float64) error {
-	if err := p.buf.WriteByte(byte(DOUBLE)); err != nil {
-		return err
+// AppendPack 与 Pack 相同，但将数据包追加到 dst 之后返回；dst 容量足够时不分配内存
+func (p *Packer) AppendPack(dst []byte, data SFSObject, compress bool) ([]byte, error) {
+	if !p.busy.CompareAndSwap(false, true) {
+		return nil, ErrConcurrentUse
 	}
-	return binary.Write(p.buf, binary.BigEndian, v)
-}
+	defer p.busy.Store(false)
 
-func (p *Packer) encodeUtfString(v string) error {
-	if err := p.buf.WriteByte(byte(UTF_STRING)); err != nil {
-		return err
-	}
-	strBytes := []byte(v)
-	if len(strBytes) > math.MaxUint16 {
-		return errors.New("string too long")
-	}
-	if err := binary.Write(p.buf, binary.BigEndian, uint16(len(strBytes))); err != nil {
-		return err
+	body, err := p.enc.appendSFSObject(p.buf[:0], data)
+	if err != nil {
+		return nil, err
 	}
-	_, err := p.buf.Write(strBytes)
-	return err
+	p.buf = body
+	return appendPacket(dst, body, compress)
 }
 
-func (p *Packer) encodeBoolArray(v []bool) error {
-	if err := p.buf.WriteByte(byte(BOOL_ARRAY)); err != nil {
-		return err
-	}
-	if err := binary.Write(p.buf, binary.BigEndian, uint32(len(v))); err != nil {
-		return err
-	}
-	for _, b := range v {
-		var val byte
-		if b {
-			val = 1
-		}
-		if err := p.buf.WriteByte(val); err != nil {
-			return err
-		}
+// Reset 释放内部缓冲区，例如在打包过一个很大的消息之后；与 Pack 同时调用时返回 ErrConcurrentUse
+func (p *Packer) Reset() error {
+	if !p.busy.CompareAndSwap(false, true) {
+		return ErrConcurrentUse
 	}
+	defer p.busy.Store(false)
+	p.buf = nil
 	return nil
 }
 
-func (p *Packer) encodeByteArray(v []byte) error {
-	if err := p.buf.WriteByte(byte(BYTE_ARRAY)); err != nil {
-		return err
-	}
-	if err := binary.Write(p.buf, binary.BigEndian, uint32(len(v))); err != nil {
-		return err
+// appendPacket 将包头和对象数据（需要时压缩）追加到 dst
+func appendPacket(dst, body []byte, compress bool) ([]byte, error) {
+	var firstByte byte
+	if compress {
+		compressed := getBuffer()
+		defer putBuffer(compressed)
+		if err := deflate(compressed, body); err != nil {
+			return nil, err
+		}
+		body = compressed.Bytes()
+		firstByte |= 32 // Set compression flag
 	}
-	_, err := p.buf.Write(v)
-	return err
-}
 
-func (p *Packer) encodeShortArray(v []int16) error {
-	if err := p.buf.WriteByte(byte(SHORT_ARRAY)); err != nil {
-		return err
+	if dst == nil {
+		dst = make([]byte, 0, len(body)+5)
 	}
-	if err := binary.Write(p.buf, binary.BigEndian, uint16(len(v))); err != nil {
-		return err
+	if len(body) > math.MaxUint16 {
+		dst = append(dst, firstByte|8) // Set 4-byte length flag
+		dst = binary.BigEndian.AppendUint32(dst, uint32(len(body)))
+	} else {
+		dst = append(dst, firstByte)
+		dst = binary.BigEndian.AppendUint16(dst, uint16(len(body)))
 	}
-	return binary.Write(p.buf, binary.BigEndian, v)
+	return append(dst, body...), nil
 }
 
-func (p *Packer) encodeIntArray(v []int32) error {
-	if err := p.buf.WriteByte(byte(INT_ARRAY)); err != nil {
-		return err
-	}
-	if err := binary.Write(p.buf, binary.BigEndian, uint16(len(v))); err != nil {
-		return err
-	}
-	return binary.Write(p.buf, binary.BigEndian, v)
+// encoder 保存编码时的选项
+type encoder struct {
+	modifiedUTF8 bool
 }
 
-func (p *Packer) encodeLongArray(v []int64) error {
-	if err := p.buf.WriteByte(byte(LONG_ARRAY)); err != nil {
-		return err
-	}
-	if err := binary.Write(p.buf, binary.BigEndian, uint16(len(v))); err != nil {
-		return err
+func (e encoder) appendSFSObject(b []byte, obj SFSObject) ([]byte, error) {
+	if len(obj) > maxCount {
+		return nil, fmt.Errorf("%w: %d keys", ErrLimitExceeded, len(obj))
 	}
-	return binary.Write(p.buf, binary.BigEndian, v)
-}
+	b = append(b, byte(SFS_OBJECT))
+	b = binary.BigEndian.AppendUint16(b, uint16(len(obj)))
 
-func (p *Packer) encodeFloatArray(v []float32) error {
-	if err := p.buf.WriteByte(byte(FLOAT_ARRAY)); err != nil {
-		return err
-	}
-	if err := binary.Write(p.buf, binary.BigEndian, uint16(len(v))); err != nil {
-		return err
+	var err error
+	for key, value := range obj {
+		if b, err = e.appendUTF(b, key, maxKeyLength, "key"); err != nil {
+			return nil, prefixPath(err, key, nil, NULL)
+		}
+		if b, err = e.appendValue(b, value); err != nil {
+			return nil, prefixPath(err, key, reflect.TypeOf(value), wireTypeOf(value))
+		}
 	}
-	return binary.Write(p.buf, binary.BigEndian, v)
+	return b, nil
 }
 
-func (p *Packer) encodeDoubleArray(v []float64) error {
-	if err := p.buf.WriteByte(byte(DOUBLE_ARRAY)); err != nil {
-		return err
+func (e encoder) appendSFSArray(b []byte, arr SFSArray) ([]byte, error) {
+	if len(arr) > maxCount {
+		return nil, fmt.Errorf("%w: %d elements", ErrLimitExceeded, len(arr))
 	}
-	if err := binary.Write(p.buf, binary.BigEndian, uint16(len(v))); err != nil {
-		return err
+	b = append(b, byte(SFS_ARRAY))
+	b = binary.BigEndian.AppendUint16(b, uint16(len(arr)))
+
+	var err error
+	for i, value := range arr {
+		if b, err = e.appendValue(b, value); err != nil {
+			return nil, prefixPath(err, indexSeg(i), reflect.TypeOf(value), wireTypeOf(value))
+		}
 	}
-	return binary.Write(p.buf, binary.BigEndian, v)
+	return b, nil
 }
 
-func (p *Packer) encodeUtfStringArray(v []string) error {
-	if err := p.buf.WriteByte(byte(UTF_STRING_ARRAY)); err != nil {
-		return err
-	}
-	if err := binary.Write(p.buf, binary.BigEndian, uint16(len(v))); err != nil {
-		return err
+// appendValue 将一个 SFS 值（含类型字节）追加到 b
+func (e encoder) appendValue(b []byte, value interface{}) ([]byte, error) {
+	if value == nil {
+		return append(b, byte(NULL)), nil
 	}
-	for _, s := range v {
-		strBytes := []byte(s)
-		if len(strBytes) > math.MaxUint16 {
-			return errors.New("string too long")
+
+	switch v := value.(type) {
+	case bool:
+		return append(b, byte(BOOL), boolByte(v)), nil
+	case byte:
+		return append(b, byte(BYTE), v), nil
+	case int16:
+		return binary.BigEndian.AppendUint16(append(b, byte(SHORT)), uint16(v)), nil
+	case int32:
+		return binary.BigEndian.AppendUint32(append(b, byte(INT)), uint32(v)), nil
+	case int64:
+		return binary.BigEndian.AppendUint64(append(b, byte(LONG)), uint64(v)), nil
+	case int:
+		// 与 Marshal 相同，int 映射为 LONG
+		return binary.BigEndian.AppendUint64(append(b, byte(LONG)), uint64(v)), nil
+	case float32:
+		return binary.BigEndian.AppendUint32(append(b, byte(FLOAT)), math.Float32bits(v)), nil
+	case float64:
+		return binary.BigEndian.AppendUint64(append(b, byte(DOUBLE)), math.Float64bits(v)), nil
+	case string:
+		return e.appendUTF(append(b, byte(UTF_STRING)), v, maxStringLength, "string")
+	case []bool:
+		b, err := appendArrayHeader(b, BOOL_ARRAY, len(v))
+		if err != nil {
+			return nil, err
 		}
-		if err := binary.Write(p.buf, binary.BigEndian, uint16(len(strBytes))); err != nil {
-			return err
+		for _, x := range v {
+			b = append(b, boolByte(x))
 		}
-		if _, err := p.buf.Write(strBytes); err != nil {
-			return err
+		return b, nil
+	case []byte:
+		if uint64(len(v)) > math.MaxUint32 {
+			return nil, fmt.Errorf("%w: %d bytes", ErrLimitExceeded, len(v))
+		}
+		b = binary.BigEndian.AppendUint32(append(b, byte(BYTE_ARRAY)), uint32(len(v)))
+		return append(b, v...), nil
+	case []int16:
+		b, err := appendArrayHeader(b, SHORT_ARRAY, len(v))
+		if err != nil {
+			return nil, err
+		}
+		for _, x := range v {
+			b = binary.BigEndian.AppendUint16(b, uint16(x))
+		}
+		return b, nil
+	case []int32:
+		b, err := appendArrayHeader(b, INT_ARRAY, len(v))
+		if err != nil {
+			return nil, err
+		}
+		for _, x := range v {
+			b = binary.BigEndian.AppendUint32(b, uint32(x))
+		}
+		return b, nil
+	case []int64:
+		b, err := appendArrayHeader(b, LONG_ARRAY, len(v))
+		if err != nil {
+			return nil, err
+		}
+		for _, x := range v {
+			b = binary.BigEndian.AppendUint64(b, uint64(x))
+		}
+		return b, nil
+	case []float32:
+		b, err := appendArrayHeader(b, FLOAT_ARRAY, len(v))
+		if err != nil {
+			return nil, err
+		}
+		for _, x := range v {
+			b = binary.BigEndian.AppendUint32(b, math.Float32bits(x))
+		}
+		return b, nil
+	case []float64:
+		b, err := appendArrayHeader(b, DOUBLE_ARRAY, len(v))
+		if err != nil {
+			return nil, err
+		}
+		for _, x := range v {
+			b = binary.BigEndian.AppendUint64(b, math.Float64bits(x))
 		}
+		return b, nil
+	case []string:
+		b, err := appendArrayHeader(b, UTF_STRING_ARRAY, len(v))
+		if err != nil {
+			return nil, err
+		}
+		for i, s := range v {
+			if b, err = e.appendUTF(b, s, maxStringLength, "string"); err != nil {
+				return nil, prefixPath(err, indexSeg(i), nil, UTF_STRING_ARRAY)
+			}
+		}
+		return b, nil
+	case map[string]interface{}:
+		return e.appendSFSObject(b, SFSObject(v))
+	case SFSObject:
+		return e.appendSFSObject(b, v)
+	case SFSArray:
+		return e.appendSFSArray(b, v)
+	default:
+		// 命名类型、结构体、注册了编码函数的类型等先转换为 SFS 值
+		converted, err := toWireValue(value)
+		if err != nil {
+			return nil, err
+		}
+		if !isWireValue(converted) {
+			return nil, fmt.Errorf("%w: %T", ErrUnsupportedType, value)
+		}
+		return e.appendValue(b, converted)
 	}
-	return nil
 }
 
-func (p *Packer) encodeSFSArray(v SFSArray) error {
-	if err := p.buf.WriteByte(byte(SFS_ARRAY)); err != nil {
-		return err
+// appendUTF 追加 2 字节长度和字符串内容，长度超过 limit 时返回错误。
+// modifiedUTF8 时按 Java DataOutputStream.writeUTF 的格式编码。
+func (e encoder) appendUTF(b []byte, s string, limit int, what string) ([]byte, error) {
+	n := len(s)
+	if e.modifiedUTF8 {
+		n = modifiedUTF8Len(s)
 	}
-	if err := binary.Write(p.buf, binary.BigEndian, uint16(len(v))); err != nil {
-		return err
+	if n > limit {
+		return nil, fmt.Errorf("%w: %s length %d", ErrLimitExceeded, what, n)
 	}
-	for _, value := range v {
-		if err := p.encodeValue(value); err != nil {
-			return err
-		}
+	b = binary.BigEndian.AppendUint16(b, uint16(n))
+	if e.modifiedUTF8 {
+		return appendModifiedUTF8(b, s), nil
 	}
-	return nil
+	return append(b, s...), nil
+}
+
+// appendArrayHeader 追加定长数组的类型字节和 2 字节元素数量
+func appendArrayHeader(b []byte, typ DataType, n int) ([]byte, error) {
+	if n > maxCount {
+		return nil, fmt.Errorf("%w: %d elements", ErrLimitExceeded, n)
+	}
+	b = append(b, byte(typ))
+	return binary.BigEndian.AppendUint16(b, uint16(n)), nil
+}
+
+func boolByte(v bool) byte {
+	if v {
+		return 1
+	}
+	return 0
 }
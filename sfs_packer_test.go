@@ -0,0 +1,195 @@
+package sfs
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// BOOL_ARRAY 和 LONG_ARRAY 与其他基本类型数组一样使用 2 字节的元素个数
+func TestArrayCountWidth(t *testing.T) {
+	obj := SFSObject{
+		"b": []bool{true, false},
+		"l": []int64{1, -1},
+	}
+	data, err := NewPacker().Pack(obj, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantBool := []byte{'b', byte(BOOL_ARRAY), 0, 2, 1, 0}
+	wantLong := []byte{'l', byte(LONG_ARRAY), 0, 2, 0, 0, 0, 0, 0, 0, 0, 1}
+	if !bytes.Contains(data, wantBool) || !bytes.Contains(data, wantLong) {
+		t.Fatalf("unexpected array encoding: % x", data)
+	}
+
+	v, err := NewUnpacker(data).Unpack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, obj) {
+		t.Fatalf("round trip = %v, want %v", v, obj)
+	}
+
+	// 按 SFS2X 服务端的布局写出的字节，不经过 Packer 直接解码
+	packet := []byte{
+		0x00, 0x00, 0x19, // 包头，长度 25
+		byte(SFS_OBJECT), 0x00, 0x02,
+		0x00, 0x01, 'b', byte(BOOL_ARRAY), 0x00, 0x02, 0x01, 0x00,
+		0x00, 0x01, 'l', byte(LONG_ARRAY), 0x00, 0x01, 0x00, 0x00, 0x01, 0x97, 0x2e, 0x4b, 0x6a, 0x1e,
+	}
+	v, err = NewUnpacker(packet).Unpack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := SFSObject{"b": []bool{true, false}, "l": []int64{1748828383774}}
+	if !reflect.DeepEqual(v, want) {
+		t.Fatalf("Unpack = %v, want %v", v, want)
+	}
+}
+
+// 抓包得到的服务端数据包中，DOUBLE_ARRAY、INT_ARRAY、UTF_STRING_ARRAY 都使用
+// 2 字节的元素个数：按这个宽度计算的大小与包体的实际长度一致
+func TestCapturedArrayCounts(t *testing.T) {
+	packet, err := base64.StdEncoding.DecodeString(spinPacket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(packet[3:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := NewUnpacker(packet).Unpack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := v.(SFSObject)
+	n, err := Size(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n-3 != len(body) {
+		t.Fatalf("Size = %d, captured body is %d bytes", n-3, len(body))
+	}
+	data, err := NewPacker().Pack(obj, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data)-3 != len(body) {
+		t.Fatalf("Pack body = %d bytes, captured body is %d bytes", len(data)-3, len(body))
+	}
+}
+
+// 压缩包的头部长度是压缩后数据的长度
+func TestPackCompressed(t *testing.T) {
+	obj := SFSObject{"s": strings.Repeat("abc", 100), "n": int32(7)}
+	data, err := NewPacker().Pack(obj, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data[0]&0x20 == 0 {
+		t.Fatalf("compressed flag not set: %#x", data[0])
+	}
+	if n := int(binary.BigEndian.Uint16(data[1:3])); n != len(data)-3 {
+		t.Fatalf("header length = %d, want %d", n, len(data)-3)
+	}
+
+	v, err := NewUnpacker(data).Unpack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, obj) {
+		t.Fatalf("round trip = %v, want %v", v, obj)
+	}
+
+	// 抓包得到的服务端压缩包，头部同样是压缩后的长度
+	packet, err := base64.StdEncoding.DecodeString(spinPacket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if packet[0]&0x20 == 0 {
+		t.Fatalf("captured compressed flag not set: %#x", packet[0])
+	}
+	if n := int(binary.BigEndian.Uint16(packet[1:3])); n != len(packet)-3 {
+		t.Fatalf("captured header length = %d, want %d", n, len(packet)-3)
+	}
+	v, err = NewUnpacker(packet).Unpack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err = NewPacker().Pack(v.(SFSObject), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data[0]&0x20 == 0 || int(binary.BigEndian.Uint16(data[1:3])) != len(data)-3 {
+		t.Fatalf("repacked header = % x, body %d bytes", data[:3], len(data)-3)
+	}
+}
+
+// compatCases 是 testdata 中兼容性样本的输入，样本由重写之前的 Packer 生成。
+// 每个对象只有一个 key，其余的值放在 SFSArray 中，使编码结果与 map 的遍历顺序无关。
+var compatCases = []struct {
+	name     string
+	obj      SFSObject
+	compress bool
+}{
+	{"scalars", SFSObject{"v": SFSArray{
+		nil, true, false, byte(200), int16(-2), int32(-70000), int32(12345), int64(7499736444769),
+		float32(1.5), 800.4, "h5.spinResponse", "", "中文 ✓",
+	}}, false},
+	{"arrays", SFSObject{"v": SFSArray{
+		[]bool{true, false}, []byte{1, 2, 3}, []int16{1, -1}, []int32{1 << 20, -3},
+		[]int64{1, -7499736444769}, []float32{0.5, -2}, []float64{1.01, 24.24},
+		[]string{"a", "bc", ""}, []bool{}, []byte{}, []string{},
+	}}, false},
+	{"nested", SFSObject{"p": SFSObject{"c": SFSArray{
+		SFSObject{"x": SFSArray{}}, SFSArray{SFSObject{}}, SFSObject{"code": int16(200)},
+	}}}, false},
+	{"nested_zlib", SFSObject{"p": SFSObject{"c": SFSArray{
+		"spin", "spin", "spin", []float64{1.01, 1.05, 1.1, 1.15, 1.21}, SFSObject{"ok": true},
+	}}}, true},
+	{"large", SFSObject{"b": bytes.Repeat([]byte{7}, 70000)}, false},
+	{"large_zlib", SFSObject{"b": bytes.Repeat([]byte{7}, 70000)}, true},
+}
+
+// 当前的 Packer 与旧实现逐字节一致，Unpacker 解码旧实现的输出后重新打包得到相同的字节。
+// 所有样本共用一个 Packer，同时检查重复使用时不会残留上一次的数据。
+func TestCompatCorpus(t *testing.T) {
+	p := NewPacker()
+	for _, c := range compatCases {
+		want, err := os.ReadFile(filepath.Join("testdata", c.name+".sfs"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := p.Pack(c.obj, c.compress)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if !bytes.Equal(data, want) {
+			t.Errorf("%s: Pack output differs from testdata", c.name)
+		}
+
+		v, err := NewUnpacker(want).Unpack()
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		data, err = p.Pack(v.(SFSObject), c.compress)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if !bytes.Equal(data, want) {
+			t.Errorf("%s: repacked Unpack output differs from testdata", c.name)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package sfs
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// checkPayloadType 检查 json/sfsbin 字段的 Go 类型和 tag 中指定的数据类型
+func checkPayloadType(info fieldInfo, t reflect.Type) error {
+	switch info.payload {
+	case payloadJSON:
+		switch info.dataType {
+		case NULL, BYTE_ARRAY, UTF_STRING, TEXT:
+			return nil
+		}
+		return fmt.Errorf("json cannot be stored as %s", info.dataType)
+	case payloadSFSBin:
+		if info.dataType != NULL && info.dataType != BYTE_ARRAY {
+			return fmt.Errorf("sfsbin cannot be stored as %s", info.dataType)
+		}
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct && t.Kind() != reflect.Map {
+			return fmt.Errorf("sfsbin cannot be used with %s", t)
+		}
+	}
+	return nil
+}
+
+// encodePayload 将字段值序列化为 JSON 或打包的 SFS_OBJECT（字符串按 enc 编码），
+// 以 BYTE_ARRAY（默认）或 UTF_STRING/TEXT 存放
+func encodePayload(val reflect.Value, format payloadFormat, dtype DataType, enc StringEncoding) (interface{}, error) {
+	var data []byte
+	switch format {
+	case payloadJSON:
+		var err error
+		if data, err = json.Marshal(val.Interface()); err != nil {
+			return nil, err
+		}
+	case payloadSFSBin:
+		if val.Kind() == reflect.Ptr && val.IsNil() {
+			return nil, nil
+		}
+		obj, err := convertToSFSValue(val, SFS_OBJECT)
+		if err != nil {
+			return nil, err
+		}
+		m, _ := obj.(SFSObject)
+		if data, err = (encoder{modifiedUTF8: enc == ModifiedUTF8}).appendSFSObject(nil, m); err != nil {
+			return nil, err
+		}
+	}
+
+	if dtype == UTF_STRING || dtype == TEXT {
+		return string(data), nil
+	}
+	return data, nil
+}
+
+// decodePayload 是 encodePayload 的逆过程，接受 BYTE_ARRAY 或字符串
+func (d *decoder) decodePayload(field reflect.Value, sfsValue interface{}, format payloadFormat, enc StringEncoding) error {
+	var data []byte
+	switch v := sfsValue.(type) {
+	case nil:
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot decode %T as %s payload", sfsValue, format)
+	}
+
+	// 与其他字段一样整体替换，不与原有的 map/slice 合并
+	field.Set(reflect.Zero(field.Type()))
+	switch format {
+	case payloadJSON:
+		return json.Unmarshal(data, field.Addr().Interface())
+	case payloadSFSBin:
+		u := &Unpacker{data: data, opts: UnpackOptions{Strings: enc, InvalidUTF8: d.opts.InvalidUTF8}}
+		obj, err := u.decodeValue()
+		if err != nil {
+			return err
+		}
+		if _, ok := obj.(SFSObject); !ok {
+			return fmt.Errorf("sfsbin payload is %s, not SFS_OBJECT", wireTypeOf(obj))
+		}
+		if u.pos < len(data) {
+			return &DecodeError{Offset: u.pos, Type: SFS_OBJECT, Err: fmt.Errorf("%d bytes of trailing data", len(data)-u.pos)}
+		}
+		return d.convertFromSFSValue(field, obj, SFS_OBJECT)
+	}
+	return nil
+}
+
+func (f payloadFormat) String() string {
+	switch f {
+	case payloadJSON:
+		return "json"
+	case payloadSFSBin:
+		return "sfsbin"
+	default:
+		return "none"
+	}
+}
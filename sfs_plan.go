@@ -0,0 +1,367 @@
+package sfs
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// fieldPlan 是单个结构体字段编译后的编解码计划
+type fieldPlan struct {
+	fieldInfo
+	index  int
+	goName string
+	group  *bitGroup // bit=N 字段组，index 为组内第一个字段
+
+	optionalSet bool    // Optional[T] 字段，Set 为 false 时省略
+	checks      []check // min=、max= 等约束
+
+	encode func(val reflect.Value) (interface{}, error)
+	decode func(d *decoder, field reflect.Value, sfsValue interface{}) error
+}
+
+// structPlan 是结构体类型编译后的字段计划，按字段声明顺序排列
+type structPlan struct {
+	typ    reflect.Type
+	tuple  bool // 嵌入了 Tuple，编码为 SFSArray，fields 按 index= 排序
+	fields []fieldPlan
+	byName map[string]*fieldPlan // key 和别名
+	byFold map[string]*fieldPlan // 小写的 key 和别名，用于大小写不敏感匹配
+
+	unknown []error // 无法识别的 tag 选项，只由 Precompile 报告
+}
+
+type planEntry struct {
+	plan *structPlan
+	err  error
+}
+
+// planCache 缓存 reflect.Type -> *planEntry，可并发访问
+var planCache sync.Map
+
+// cachedPlan 返回结构体类型的字段计划，首次访问时编译并缓存
+func cachedPlan(t reflect.Type) (*structPlan, error) {
+	if e, ok := planCache.Load(t); ok {
+		entry := e.(*planEntry)
+		return entry.plan, entry.err
+	}
+
+	plan, err := compilePlan(t)
+	e, _ := planCache.LoadOrStore(t, &planEntry{plan: plan, err: err})
+	entry := e.(*planEntry)
+	return entry.plan, entry.err
+}
+
+// compilePlan 解析结构体所有字段的 tag，返回所有错误而不是在第一个错误处停止
+func compilePlan(t reflect.Type) (*structPlan, error) {
+	plan := &structPlan{typ: t}
+	var errs []error
+	seen := make(map[string]string)
+	groups := make(map[string]int) // bit=N 字段组的 key -> plan.fields 下标
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		// Skip unexported fields
+		if !field.IsExported() {
+			continue
+		}
+		if field.Anonymous && field.Type == tupleType {
+			plan.tuple = true
+			continue
+		}
+
+		info, err := parseTag(field)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s.%s: %v", t, field.Name, err))
+			continue
+		}
+		for _, opt := range info.unknown {
+			plan.unknown = append(plan.unknown, fmt.Errorf("%s.%s: unknown tag option: %q", t, field.Name, opt))
+		}
+
+		// Optional[T] / Nullable[T] 按 T 检查和编解码，再包装
+		ft, wrapOpt, wrapNull, err := unwrapField(field.Type)
+		if err == nil && (wrapOpt || wrapNull) && info.bits.isGroup() {
+			err = fmt.Errorf("bit=N cannot be used with %s", field.Type)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s.%s: %v", t, field.Name, err))
+			continue
+		}
+
+		if pos, ok := groups[info.name]; ok && info.bits.isGroup() {
+			// 加入已有的字段组，组的别名和 optional 取所有字段的并集
+			fp := &plan.fields[pos]
+			if len(info.constraints) > 0 {
+				errs = append(errs, fmt.Errorf("%s.%s: constraints cannot be used with bit=N", t, field.Name))
+				continue
+			}
+			if err := fp.group.add(info, i, field.Type); err != nil {
+				errs = append(errs, fmt.Errorf("%s.%s: %v", t, field.Name, err))
+				continue
+			}
+			for _, alias := range info.aliases {
+				if prev, ok := seen[alias]; ok && prev != fp.goName {
+					errs = append(errs, fmt.Errorf("%s.%s: key %q already used by field %s", t, field.Name, alias, prev))
+					continue
+				}
+				if _, ok := seen[alias]; !ok {
+					seen[alias] = fp.goName
+					fp.aliases = append(fp.aliases, alias)
+				}
+			}
+			fp.optional = fp.optional || info.optional
+			continue
+		}
+
+		if info.discriminator != "" {
+			err = checkVariantType(info, ft)
+		} else if info.bits.isGroup() {
+			err = (&bitGroup{}).add(info, i, field.Type)
+		} else if info.bits != nil {
+			err = checkBitsType(info, ft)
+		} else if info.decimal != nil {
+			err = checkDecimalType(info, ft)
+		} else if info.payload != payloadNone {
+			err = checkPayloadType(info, ft)
+		} else if err = checkPlainDecimal(ft); err == nil {
+			err = checkDataType(info.dataType, ft)
+		}
+		var checks []check
+		if err == nil && len(info.constraints) > 0 {
+			checks, err = compileConstraints(info.constraints, ft)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s.%s: %v", t, field.Name, err))
+			continue
+		}
+
+		dup := false
+		for _, key := range append([]string{info.name}, info.aliases...) {
+			if prev, ok := seen[key]; ok {
+				errs = append(errs, fmt.Errorf("%s.%s: key %q already used by field %s", t, field.Name, key, prev))
+				dup = true
+				break
+			}
+			seen[key] = field.Name
+		}
+		if dup {
+			continue
+		}
+
+		fp := fieldPlan{
+			fieldInfo: info,
+			index:     i,
+			goName:    field.Name,
+			checks:    checks,
+		}
+		dtype := info.dataType
+		if key := info.discriminator; key != "" {
+			fp.encode = func(val reflect.Value) (interface{}, error) {
+				return encodeVariant(val, key)
+			}
+			fp.decode = func(d *decoder, field reflect.Value, sfsValue interface{}) error {
+				return d.decodeVariant(field, sfsValue, key)
+			}
+		} else if info.bits.isGroup() {
+			g := &bitGroup{}
+			g.add(info, i, field.Type)
+			fp.group = g
+			fp.encode = g.encode
+			fp.decode = func(d *decoder, val reflect.Value, sfsValue interface{}) error {
+				return g.decode(val, sfsValue)
+			}
+			groups[info.name] = len(plan.fields)
+		} else if bits := info.bits; bits != nil {
+			if dtype == NULL {
+				dtype = BYTE_ARRAY
+				fp.dataType = BYTE_ARRAY
+			}
+			fp.encode = func(val reflect.Value) (interface{}, error) {
+				return encodeBits(val, bits, dtype)
+			}
+			fp.decode = func(d *decoder, field reflect.Value, sfsValue interface{}) error {
+				return decodeBits(field, sfsValue, bits)
+			}
+		} else if dec := info.decimal; dec != nil {
+			fp.encode = func(val reflect.Value) (interface{}, error) {
+				return encodeDecimal(val, dec)
+			}
+			fp.decode = func(d *decoder, field reflect.Value, sfsValue interface{}) error {
+				return d.decodeDecimal(field, sfsValue, dec)
+			}
+		} else if info.payload != payloadNone {
+			format, enc := info.payload, info.payloadStrings
+			fp.encode = func(val reflect.Value) (interface{}, error) {
+				return encodePayload(val, format, dtype, enc)
+			}
+			fp.decode = func(d *decoder, field reflect.Value, sfsValue interface{}) error {
+				return d.decodePayload(field, sfsValue, format, enc)
+			}
+		} else {
+			fp.encode = func(val reflect.Value) (interface{}, error) {
+				return convertToSFSValue(val, dtype)
+			}
+			fp.decode = func(d *decoder, field reflect.Value, sfsValue interface{}) error {
+				return d.convertFromSFSValue(field, sfsValue, dtype)
+			}
+		}
+		if wrapOpt || wrapNull {
+			fp.encode = wrapEncode(fp.encode, wrapOpt, wrapNull)
+			fp.decode = wrapDecode(fp.decode, wrapOpt, wrapNull)
+			fp.optional = fp.optional || wrapOpt
+			fp.optionalSet = wrapOpt
+		}
+		plan.fields = append(plan.fields, fp)
+	}
+
+	for _, pos := range groups {
+		fp := &plan.fields[pos]
+		if err := fp.group.finish(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: key %q: %v", t, fp.name, err))
+		}
+		fp.dataType = fp.group.dtype
+	}
+
+	if plan.tuple && len(errs) == 0 {
+		errs = append(errs, compileTuple(plan)...)
+	} else if !plan.tuple {
+		for _, fp := range plan.fields {
+			if fp.pos >= 0 {
+				errs = append(errs, fmt.Errorf("%s.%s: index= requires embedded sfs.Tuple", t, fp.goName))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		// 计划已经无法使用，未知选项一起报告
+		return nil, errors.Join(append(errs, plan.unknown...)...)
+	}
+
+	plan.byName = make(map[string]*fieldPlan, len(plan.fields))
+	plan.byFold = make(map[string]*fieldPlan, len(plan.fields))
+	for i := range plan.fields {
+		fp := &plan.fields[i]
+		for _, key := range append([]string{fp.name}, fp.aliases...) {
+			plan.byName[key] = fp
+			if _, ok := plan.byFold[strings.ToLower(key)]; !ok {
+				plan.byFold[strings.ToLower(key)] = fp
+			}
+		}
+	}
+	return plan, nil
+}
+
+// value 返回结构体 val 中字段计划对应的值，bit=N 字段组对应整个结构体
+func (fp *fieldPlan) value(val reflect.Value) reflect.Value {
+	if fp.group != nil {
+		return val
+	}
+	return val.Field(fp.index)
+}
+
+// omit 报告 optional 字段是否为零值，Marshal 时省略；Optional[T] 在 Set 为 false 时、
+// 字段组在所有位都为 0 时省略
+func (fp *fieldPlan) omit(v reflect.Value) bool {
+	if !fp.optional {
+		return false
+	}
+	if fp.optionalSet {
+		return !v.Field(1).Bool()
+	}
+	if fp.group != nil {
+		return fp.group.value(v) == 0
+	}
+	return isZero(v)
+}
+
+// checkDataType 检查 tag 中指定的数据类型是否能用于该 Go 类型
+func checkDataType(dtype DataType, t reflect.Type) error {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if dtype == NULL || t.Kind() == reflect.Interface {
+		return nil
+	}
+
+	ok := false
+	switch dtype {
+	case BOOL:
+		ok = t.Kind() == reflect.Bool
+	case BYTE, SHORT, INT, LONG:
+		ok = isIntKind(t.Kind()) || isUintKind(t.Kind())
+	case FLOAT, DOUBLE:
+		ok = t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64
+	case UTF_STRING, TEXT:
+		ok = t.Kind() == reflect.String
+	case SFS_OBJECT:
+		ok = t.Kind() == reflect.Struct || t.Kind() == reflect.Map
+	default:
+		if dtype >= BOOL_ARRAY && dtype <= SFS_ARRAY {
+			ok = t.Kind() == reflect.Slice || t.Kind() == reflect.Array
+		}
+	}
+
+	if !ok {
+		return fmt.Errorf("type=%s cannot be used with %s", dtype, t)
+	}
+	return nil
+}
+
+func isIntKind(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Int64
+}
+
+func isUintKind(k reflect.Kind) bool {
+	return k >= reflect.Uint && k <= reflect.Uintptr
+}
+
+// Precompile 预先编译并缓存结构体类型（及其嵌套结构体）的字段计划，
+// 用于在启动时校验 tag，一次性返回所有 tag 错误。
+// 除了 Marshal/Unmarshal 也会报告的错误，Precompile 还报告它们忽略的未知选项（如拼写错误）。
+// v 可以是结构体值、结构体指针或 reflect.Type。
+func Precompile(v interface{}) error {
+	t, ok := v.(reflect.Type)
+	if !ok {
+		t = reflect.TypeOf(v)
+	}
+	if t == nil {
+		return errors.New("cannot precompile nil")
+	}
+
+	var errs []error
+	precompileType(t, make(map[reflect.Type]bool), &errs)
+	return errors.Join(errs...)
+}
+
+func precompileType(t reflect.Type, visited map[reflect.Type]bool, errs *[]error) {
+	for {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+			t = t.Elem()
+			continue
+		}
+		break
+	}
+	if t.Kind() != reflect.Struct || visited[t] {
+		return
+	}
+	visited[t] = true
+
+	plan, err := cachedPlan(t)
+	if err != nil {
+		*errs = append(*errs, err)
+	} else {
+		*errs = append(*errs, plan.unknown...)
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.IsExported() {
+			precompileType(field.Type, visited, errs)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package sfs
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// EncoderFunc 将自定义类型的值转换为 SFS 值（bool、int32、string、SFSObject 等 Packer 支持的类型）
+type EncoderFunc func(v reflect.Value) (interface{}, error)
+
+// DecoderFunc 将 SFS 值写入自定义类型的 v，v 可以直接 Set
+type DecoderFunc func(sfsValue interface{}, v reflect.Value) error
+
+// encoders / decoders 保存 reflect.Type -> EncoderFunc / DecoderFunc
+var encoders, decoders sync.Map
+
+// wireTypes 保存 reflect.Type -> DataType，记录编码函数总是返回的数据类型（如 RegisterEnum 的 As），
+// 这样的类型的切片编码为对应的基本类型数组而不是 SFS_ARRAY
+var wireTypes sync.Map
+
+// RegisterEncoder 为类型 t 注册编码函数，Marshal 的字段、SFSObject/SFSArray 中的值
+// 以及切片、map 的元素遇到 t 时都使用 fn，优先于 tag 中的 type=。
+// 应在程序启动时注册；重复注册会覆盖之前的函数。
+func RegisterEncoder(t reflect.Type, fn EncoderFunc) {
+	encoders.Store(t, fn)
+	wireTypes.Delete(t)
+}
+
+// RegisterDecoder 为类型 t 注册解码函数，Unmarshal 写入 t 类型的字段或元素时使用 fn
+func RegisterDecoder(t reflect.Type, fn DecoderFunc) {
+	decoders.Store(t, fn)
+}
+
+func lookupEncoder(t reflect.Type) (EncoderFunc, bool) {
+	fn, ok := encoders.Load(t)
+	if !ok {
+		return nil, false
+	}
+	return fn.(EncoderFunc), true
+}
+
+func lookupDecoder(t reflect.Type) (DecoderFunc, bool) {
+	fn, ok := decoders.Load(t)
+	if !ok {
+		return nil, false
+	}
+	return fn.(DecoderFunc), true
+}
+
+// lookupWireType 返回类型 t 的编码函数固定返回的数据类型，没有记录时 ok 为 false
+func lookupWireType(t reflect.Type) (DataType, bool) {
+	dt, ok := wireTypes.Load(t)
+	if !ok {
+		return NULL, false
+	}
+	return dt.(DataType), true
+}
+
+// primitiveArrayElems 是基本类型数组的元素对应的 Go 类型
+var primitiveArrayElems = map[DataType]reflect.Type{
+	BOOL_ARRAY:       reflect.TypeOf(false),
+	BYTE_ARRAY:       reflect.TypeOf(byte(0)),
+	SHORT_ARRAY:      reflect.TypeOf(int16(0)),
+	INT_ARRAY:        reflect.TypeOf(int32(0)),
+	LONG_ARRAY:       reflect.TypeOf(int64(0)),
+	FLOAT_ARRAY:      reflect.TypeOf(float32(0)),
+	DOUBLE_ARRAY:     reflect.TypeOf(float64(0)),
+	UTF_STRING_ARRAY: reflect.TypeOf(""),
+}
+
+// encodeRegisteredArray 用注册的编码函数编码每个元素，组成基本类型数组 dtype，
+// 编码函数的返回值必须是数组的元素类型
+func encodeRegisteredArray(val reflect.Value, fn EncoderFunc, dtype DataType) (interface{}, error) {
+	elemType := primitiveArrayElems[dtype]
+	arr := reflect.MakeSlice(reflect.SliceOf(elemType), val.Len(), val.Len())
+	for i := 0; i < val.Len(); i++ {
+		elem := val.Index(i)
+		w, err := encodeRegistered(fn, elem)
+		if err == nil && reflect.TypeOf(w) != elemType {
+			err = fmt.Errorf("encoder returned %T, which cannot be stored in %s", w, dtype)
+		}
+		if err != nil {
+			return nil, prefixPath(err, indexSeg(i), elem.Type(), wireTypeOf(w))
+		}
+		arr.Index(i).Set(reflect.ValueOf(w))
+	}
+	return arr.Interface(), nil
+}
+
+// isPrimitiveArray 报告 v 是否是基本类型数组（BOOL_ARRAY 到 UTF_STRING_ARRAY）的值
+func isPrimitiveArray(v interface{}) bool {
+	switch v.(type) {
+	case []bool, []byte, []int16, []int32, []int64, []float32, []float64, []string:
+		return true
+	}
+	return false
+}
+
+// decodeRegisteredArray 用注册的解码函数解码基本类型数组的每个元素
+func (d *decoder) decodeRegisteredArray(field reflect.Value, src reflect.Value, fn DecoderFunc) error {
+	slice, err := makeSeq(field.Type(), src.Len())
+	if err != nil {
+		return err
+	}
+	elemType := field.Type().Elem()
+	for i := 0; i < src.Len(); i++ {
+		v := src.Index(i).Interface()
+		if err := fn(v, slice.Index(i)); err != nil {
+			return prefixPath(err, indexSeg(i), elemType, wireTypeOf(v))
+		}
+	}
+	field.Set(slice)
+	return nil
+}
+
+// encodeRegistered 调用注册的编码函数，并检查返回值是 Packer 能直接编码的类型
+func encodeRegistered(fn EncoderFunc, val reflect.Value) (interface{}, error) {
+	out, err := fn(val)
+	if err != nil {
+		return nil, err
+	}
+	if out != nil && reflect.TypeOf(out) == val.Type() {
+		return nil, fmt.Errorf("encoder for %s returned the same type", val.Type())
+	}
+	return toWireValue(out)
+}
+
+// toWireValue 将 SFSObject/SFSArray 中不能直接编码的 Go 值转换为 SFS 值：
+// 先查找注册的编码函数，否则按底层类型转换（如 type Coins int64、int8、uint16、
+// []SFSObject、[]map[string]interface{} 和结构体），规则与 Marshal 相同。
+func toWireValue(value interface{}) (interface{}, error) {
+	if isWireValue(value) {
+		return value, nil
+	}
+
+	val := reflect.ValueOf(value)
+	out, err := convertToSFSValue(val, NULL)
+	if err != nil {
+		return nil, err
+	}
+	if out == nil && (val.Kind() == reflect.Slice && !val.IsNil() || val.Kind() == reflect.Array) {
+		// convertToSFSValue 将空切片转换为 NULL，这里非 nil 的空切片应保持为空的类型化数组
+		return emptyArrayOf(val.Type().Elem()), nil
+	}
+	return out, nil
+}
+
+// isWireValue 报告 value 是否是 Packer 可以直接编码的类型
+func isWireValue(value interface{}) bool {
+	switch value.(type) {
+	case nil, bool, byte, int16, int32, int, int64, float32, float64, string,
+		[]bool, []byte, []int16, []int32, []int64, []float32, []float64, []string,
+		map[string]interface{}, SFSObject, SFSArray:
+		return true
+	default:
+		return false
+	}
+}
+
+// emptyArrayOf 返回元素类型 elem 自动推断的数组类型的空值
+func emptyArrayOf(elem reflect.Type) interface{} {
+	if _, ok := lookupEncoder(elem); ok {
+		dt, ok := lookupWireType(elem)
+		if !ok {
+			return SFSArray{}
+		}
+		return reflect.MakeSlice(reflect.SliceOf(primitiveArrayElems[arrayTypeOf(dt)]), 0, 0).Interface()
+	}
+	switch elem.Kind() {
+	case reflect.Bool:
+		return []bool{}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		switch arrayTypeOf(intWireType(elem.Kind())) {
+		case BYTE_ARRAY:
+			return []byte{}
+		case SHORT_ARRAY:
+			return []int16{}
+		case INT_ARRAY:
+			return []int32{}
+		default:
+			return []int64{}
+		}
+	case reflect.Float32:
+		return []float32{}
+	case reflect.Float64:
+		return []float64{}
+	case reflect.String:
+		return []string{}
+	default:
+		return SFSArray{}
+	}
+}
@@ -0,0 +1,183 @@
+package sfs
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// Size 返回 obj 不压缩打包后的字节数（含包头），与 len(Pack(obj, PackOptions{})) 相同。
+// obj 中有无法编码的值时返回与 Pack 相同的带路径的错误；Size 不检查协议限制，需要时先调用 Validate。
+func Size(obj SFSObject) (int, error) {
+	n, err := sizeOf(obj)
+	if err != nil {
+		return 0, err
+	}
+	if n > math.MaxUint16 {
+		return n + 5, nil
+	}
+	return n + 3, nil
+}
+
+// Validate 在打包之前检查 obj，报告所有超长的 key、字符串和数组、
+// 超过 65535 项的对象以及无法编码的 Go 类型。
+// 每个问题都是带路径的 FieldError，多个问题时返回 MultiError。
+func Validate(obj SFSObject) error {
+	return joinErrors(validateValue("", obj, nil))
+}
+
+// validateValue 检查一个值及其嵌套的元素，将问题追加到 errs
+func validateValue(path string, value interface{}, errs []error) []error {
+	fail := func(format string, args ...interface{}) []error {
+		return append(errs, &FieldError{
+			Path:     path,
+			GoType:   reflect.TypeOf(value),
+			WireType: wireTypeOf(value),
+			Err:      fmt.Errorf("%w: "+format, append([]interface{}{ErrLimitExceeded}, args...)...),
+		})
+	}
+
+	switch v := value.(type) {
+	case nil, bool, byte, int16, int32, int, int64, float32, float64:
+		return errs
+	case string:
+		if len(v) > maxStringLength {
+			return fail("string length %d", len(v))
+		}
+	case []byte:
+		if uint64(len(v)) > math.MaxUint32 {
+			return fail("%d bytes", len(v))
+		}
+	case []bool, []int16, []int32, []int64, []float32, []float64:
+		if n := reflect.ValueOf(v).Len(); n > maxCount {
+			return fail("%d elements", n)
+		}
+	case []string:
+		if len(v) > maxCount {
+			errs = fail("%d elements", len(v))
+		}
+		for i, s := range v {
+			if len(s) > maxStringLength {
+				errs = append(errs, &FieldError{
+					Path:     joinPath(path, indexSeg(i)),
+					GoType:   reflect.TypeOf(s),
+					WireType: UTF_STRING,
+					Err:      fmt.Errorf("%w: string length %d", ErrLimitExceeded, len(s)),
+				})
+			}
+		}
+	case map[string]interface{}:
+		return validateObject(path, SFSObject(v), errs)
+	case SFSObject:
+		return validateObject(path, v, errs)
+	case SFSArray:
+		if len(v) > maxCount {
+			errs = fail("%d elements", len(v))
+		}
+		for i, elem := range v {
+			errs = validateValue(joinPath(path, indexSeg(i)), elem, errs)
+		}
+	default:
+		converted, err := toWireValue(value)
+		if err == nil && !isWireValue(converted) {
+			err = fmt.Errorf("%w: %T", ErrUnsupportedType, value)
+		}
+		if err != nil {
+			return append(errs, prefixPath(err, path, reflect.TypeOf(value), NULL))
+		}
+		return validateValue(path, converted, errs)
+	}
+	return errs
+}
+
+func validateObject(path string, obj SFSObject, errs []error) []error {
+	if len(obj) > maxCount {
+		errs = append(errs, &FieldError{
+			Path:     path,
+			GoType:   reflect.TypeOf(obj),
+			WireType: SFS_OBJECT,
+			Err:      fmt.Errorf("%w: %d keys", ErrLimitExceeded, len(obj)),
+		})
+	}
+	// 按 key 排序，保证报告的顺序稳定
+	for _, key := range sortedKeys(obj) {
+		if len(key) > maxKeyLength {
+			errs = append(errs, &FieldError{
+				Path: joinPath(path, key),
+				Err:  fmt.Errorf("%w: key length %d", ErrLimitExceeded, len(key)),
+			})
+		}
+		errs = validateValue(joinPath(path, key), obj[key], errs)
+	}
+	return errs
+}
+
+// sizeOf 返回一个值编码后的字节数（含类型字节），值无法编码时返回带路径的错误
+func sizeOf(value interface{}) (int, error) {
+	switch v := value.(type) {
+	case nil:
+		return 1, nil
+	case bool, byte:
+		return 2, nil
+	case int16:
+		return 3, nil
+	case int32, float32:
+		return 5, nil
+	case int, int64, float64:
+		return 9, nil
+	case string:
+		return 3 + len(v), nil
+	case []bool:
+		return 3 + len(v), nil
+	case []byte:
+		return 5 + len(v), nil
+	case []int16:
+		return 3 + 2*len(v), nil
+	case []int32:
+		return 3 + 4*len(v), nil
+	case []float32:
+		return 3 + 4*len(v), nil
+	case []int64:
+		return 3 + 8*len(v), nil
+	case []float64:
+		return 3 + 8*len(v), nil
+	case []string:
+		n := 3
+		for _, s := range v {
+			n += 2 + len(s)
+		}
+		return n, nil
+	case map[string]interface{}:
+		return sizeOf(SFSObject(v))
+	case SFSObject:
+		n := 3
+		for key, elem := range v {
+			m, err := sizeOf(elem)
+			if err != nil {
+				return 0, prefixPath(err, key, reflect.TypeOf(elem), wireTypeOf(elem))
+			}
+			n += 2 + len(key) + m
+		}
+		return n, nil
+	case SFSArray:
+		n := 3
+		for i, elem := range v {
+			m, err := sizeOf(elem)
+			if err != nil {
+				return 0, prefixPath(err, indexSeg(i), reflect.TypeOf(elem), wireTypeOf(elem))
+			}
+			n += m
+		}
+		return n, nil
+	default:
+		// 与 Packer 相同：命名类型、结构体、注册了编码函数的类型等先转换为 SFS 值
+		converted, err := toWireValue(value)
+		if err != nil {
+			return 0, err
+		}
+		if !isWireValue(converted) {
+			return 0, fmt.Errorf("%w: %T", ErrUnsupportedType, value)
+		}
+		return sizeOf(converted)
+	}
+}
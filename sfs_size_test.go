@@ -0,0 +1,144 @@
+package sfs
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSizeAndValidate(t *testing.T) {
+	obj := SFSObject{
+		"c": "h5.spinResponse",
+		"p": SFSObject{
+			"rows":  SFSArray{SFSObject{"id": int64(1)}, []string{"a", "bc"}, nil},
+			"blob":  make([]byte, 70000),
+			"flags": []bool{true},
+			"n":     3,
+		},
+	}
+	for _, o := range []SFSObject{obj, {"a": int32(1)}} {
+		n, err := Size(o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := Pack(o, PackOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != len(data) {
+			t.Fatalf("Size = %d, Pack produced %d bytes", n, len(data))
+		}
+	}
+	if err := Validate(obj); err != nil {
+		t.Fatal(err)
+	}
+
+	bad := SFSObject{
+		strings.Repeat("k", 256): int32(1),
+		"p": SFSObject{
+			"list":  make([]int32, 70000),
+			"names": []string{"ok", strings.Repeat("x", 70000)},
+			"arr":   SFSArray{make(chan int)},
+		},
+	}
+	err := Validate(bad)
+	var me *MultiError
+	if !errors.As(err, &me) || len(me.Errors) != 4 {
+		t.Fatalf("expected 4 errors, got %v", err)
+	}
+	wantPaths := []string{strings.Repeat("k", 256), "p.arr[0]", "p.list", "p.names[1]"}
+	for i, e := range me.Errors {
+		var fe *FieldError
+		if !errors.As(e, &fe) || fe.Path != wantPaths[i] {
+			t.Fatalf("error %d: expected path %q, got %v", i, wantPaths[i], e)
+		}
+	}
+	if !errors.Is(me.Errors[1], ErrUnsupportedType) || !errors.Is(me.Errors[2], ErrLimitExceeded) {
+		t.Fatalf("unexpected sentinels: %v", err)
+	}
+
+	if _, err := Size(bad); !errors.Is(err, ErrUnsupportedType) {
+		t.Fatalf("expected ErrUnsupportedType from Size, got %v", err)
+	}
+
+	// Packer 拒绝超出限制的数据，而不是截断长度
+	_, err = Pack(SFSObject{"p": SFSObject{"list": make([]int32, 70000)}}, PackOptions{})
+	var fe *FieldError
+	if !errors.As(err, &fe) || fe.Path != "p.list" || !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("expected ErrLimitExceeded at p.list, got %v", err)
+	}
+
+}
+
+// int 在 Pack 和 Marshal 中都映射为 LONG，超出 INT 范围的值不会被截断
+func TestIntMapsToLong(t *testing.T) {
+	type row struct {
+		N int `sfs:"n"`
+	}
+	n := math.MaxInt
+	packed, err := Pack(SFSObject{"n": n}, PackOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	marshaled, err := Marshal(row{N: n})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := marshaled["n"]; got != int64(n) {
+		t.Fatalf("Marshal n = %#v, want int64", got)
+	}
+	if want, err := Pack(marshaled, PackOptions{}); err != nil || !bytes.Equal(packed, want) {
+		t.Fatalf("Pack and Marshal differ:\n   pack: %x\nmarshal: %x (%v)", packed, want, err)
+	}
+	if size, err := Size(SFSObject{"n": n}); err != nil || size != len(packed) {
+		t.Fatalf("Size = %d, %v; packed %d bytes", size, err, len(packed))
+	}
+	obj, err := Unpack(packed, UnpackOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := obj.(SFSObject)["n"]; got != int64(n) {
+		t.Fatalf("n = %#v, want LONG %d", got, n)
+	}
+	var back row
+	if err := Unmarshal(obj.(SFSObject), &back); err != nil || back.N != n {
+		t.Fatalf("Unmarshal = %+v, %v", back, err)
+	}
+}
+
+type sizeFailing struct{}
+
+type sizeEnum string
+
+// Size 在 Pack 因为无法转换的值失败时同样返回错误，而不是 (0, nil)
+func TestSizeConversionErrors(t *testing.T) {
+	RegisterEncoder(reflect.TypeOf(sizeFailing{}), func(reflect.Value) (interface{}, error) {
+		return nil, errors.New("encoder failed")
+	})
+	if err := RegisterEnum(EnumSpec[sizeEnum]{As: BYTE, Codes: map[sizeEnum]int{"a": 1}}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, value := range []interface{}{
+		uint64(math.MaxUint64),
+		sizeFailing{},
+		sizeEnum("unknown"),
+		make(chan int),
+	} {
+		obj := SFSObject{"p": SFSArray{value}}
+		_, packErr := Pack(obj, PackOptions{})
+		if packErr == nil {
+			t.Fatalf("%T: expected Pack to fail", value)
+		}
+		n, err := Size(obj)
+		if err == nil || err.Error() != packErr.Error() {
+			t.Errorf("%T: Size = %d, %v; Pack error %v", value, n, err, packErr)
+		}
+		if err := Validate(obj); err == nil {
+			t.Errorf("%T: expected Validate to fail", value)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package sfs
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Tuple 嵌入到结构体中，使结构体编码为按位置排列的 SFSArray 而不是 SFSObject，
+// 每个字段用 index=N 指定下标，例如 SmartFox 的用户数据 [id, name, privilegeId, playerId, vars]：
+//
+//	type User struct {
+//		sfs.Tuple
+//		ID          int32    `sfs:",index=0"`
+//		Name        string   `sfs:",index=1"`
+//		PrivilegeID int16    `sfs:",index=2"`
+//		PlayerID    int16    `sfs:",index=3"`
+//		Vars        SFSArray `sfs:",index=4,optional"`
+//	}
+//
+// 下标必须从 0 开始连续，optional 字段只能在末尾：Marshal 省略末尾为零值的 optional 字段，
+// Unmarshal 允许数组缺少这些元素。作为字段、切片元素或 map 值时自动使用 tuple 编码，
+// 顶层使用 MarshalArray / UnmarshalArray。
+type Tuple struct{}
+
+var tupleType = reflect.TypeOf(Tuple{})
+
+// MarshalArray 将嵌入了 Tuple 的结构体编码为 SFSArray
+func MarshalArray(v interface{}) (SFSArray, error) {
+	val := reflect.ValueOf(v)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return nil, errors.New("only structs can be marshaled to SFSArray")
+	}
+
+	plan, err := cachedPlan(val.Type())
+	if err != nil {
+		return nil, err
+	}
+	if !plan.tuple {
+		return nil, fmt.Errorf("%s does not embed sfs.Tuple", val.Type())
+	}
+	return encodeTuple(plan, val)
+}
+
+// UnmarshalArray 将 SFSArray 解码到嵌入了 Tuple 的结构体
+func UnmarshalArray(data SFSArray, v interface{}) error {
+	return UnmarshalOptions{}.UnmarshalArray(data, v)
+}
+
+// UnmarshalArray 按选项将 SFSArray 解码到嵌入了 Tuple 的结构体。
+// DisallowUnknownFields 为 true 时，多出的元素返回 ErrUnknownField。
+func (o UnmarshalOptions) UnmarshalArray(data SFSArray, v interface{}) error {
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Struct {
+		return errors.New("must pass a pointer to a struct")
+	}
+
+	d := &decoder{opts: o}
+	return d.decodeTuple(data, val.Elem())
+}
+
+// marshalStruct 将结构体编码为 SFSObject，嵌入了 Tuple 时编码为 SFSArray，
+// Optional/Nullable 编码为其中的值或 NULL
+func marshalStruct(val reflect.Value) (interface{}, error) {
+	if wrapKindOf(val.Type()) != wrapNone {
+		return encodeWrapped(val, NULL)
+	}
+	plan, err := cachedPlan(val.Type())
+	if err != nil {
+		return nil, err
+	}
+	if plan.tuple {
+		return encodeTuple(plan, val)
+	}
+	return encodeObject(plan, val)
+}
+
+// compileTuple 检查 tuple 结构体的下标并将字段按下标排序
+func compileTuple(plan *structPlan) []error {
+	var errs []error
+	for i := range plan.fields {
+		fp := &plan.fields[i]
+		if fp.pos < 0 {
+			errs = append(errs, fmt.Errorf("%s.%s: tuple field requires index=", plan.typ, fp.goName))
+		}
+		if fp.group != nil {
+			errs = append(errs, fmt.Errorf("%s.%s: bit=N cannot be used in a tuple", plan.typ, fp.goName))
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	sort.SliceStable(plan.fields, func(i, j int) bool {
+		return plan.fields[i].pos < plan.fields[j].pos
+	})
+	for i := range plan.fields {
+		fp := &plan.fields[i]
+		if fp.pos != i {
+			if i > 0 && fp.pos == plan.fields[i-1].pos {
+				return append(errs, fmt.Errorf("%s.%s: index %d already used by field %s", plan.typ, fp.goName, fp.pos, plan.fields[i-1].goName))
+			}
+			return append(errs, fmt.Errorf("%s: index %d is missing", plan.typ, i))
+		}
+		if !fp.optional && i > 0 && plan.fields[i-1].optional {
+			errs = append(errs, fmt.Errorf("%s.%s: required field after optional field", plan.typ, fp.goName))
+		}
+	}
+	return errs
+}
+
+// encodeTuple 按下标顺序编码字段，省略末尾为零值的 optional 字段
+func encodeTuple(plan *structPlan, val reflect.Value) (SFSArray, error) {
+	n := len(plan.fields)
+	for n > 0 && plan.fields[n-1].omit(plan.fields[n-1].value(val)) {
+		n--
+	}
+
+	arr := make(SFSArray, n)
+	for i := 0; i < n; i++ {
+		fp := &plan.fields[i]
+		fieldVal := fp.value(val)
+		sfsValue, err := fp.encode(fieldVal)
+		if err != nil {
+			return nil, prefixPath(err, indexSeg(i), fieldVal.Type(), fp.dataType)
+		}
+		arr[i] = sfsValue
+	}
+	return arr, nil
+}
+
+// decodeTuple 将 SFSArray 的元素按下标写入结构体字段
+func (d *decoder) decodeTuple(data SFSArray, val reflect.Value) error {
+	plan, err := cachedPlan(val.Type())
+	if err != nil {
+		return err
+	}
+	if !plan.tuple {
+		return fmt.Errorf("cannot convert SFSArray to %s, which does not embed sfs.Tuple", val.Type())
+	}
+
+	var errs []error
+	for i := range plan.fields {
+		fp := &plan.fields[i]
+		field := fp.value(val)
+
+		if i >= len(data) {
+			if fp.optional {
+				break
+			}
+			err := &FieldError{Path: indexSeg(i), GoType: field.Type(), WireType: fp.dataType, Err: ErrMissingField}
+			if !d.opts.ReportAll {
+				return err
+			}
+			errs = appendError(errs, err)
+			continue
+		}
+
+		if err := fp.decodeChecked(d, field, data[i]); err != nil {
+			err = prefixPath(err, indexSeg(i), field.Type(), wireTypeOf(data[i]))
+			if !d.opts.ReportAll {
+				return err
+			}
+			errs = appendError(errs, err)
+		}
+	}
+
+	if d.opts.DisallowUnknownFields {
+		for i := len(plan.fields); i < len(data); i++ {
+			err := &FieldError{Path: indexSeg(i), WireType: wireTypeOf(data[i]), Err: ErrUnknownField}
+			if !d.opts.ReportAll {
+				return err
+			}
+			errs = appendError(errs, err)
+		}
+	}
+
+	return joinErrors(errs)
+}
@@ -0,0 +1,116 @@
+package sfs
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type tupleUser struct {
+	Tuple
+	Name        string   `sfs:"name,index=1"`
+	ID          int32    `sfs:"id,index=0"`
+	PrivilegeID int16    `sfs:",index=2"`
+	PlayerID    int16    `sfs:",index=3"`
+	Vars        SFSArray `sfs:",index=4,optional"`
+}
+
+type tupleRoom struct {
+	Name  string      `sfs:"name"`
+	Users []tupleUser `sfs:"users"`
+	Owner *tupleUser  `sfs:"owner,optional"`
+}
+
+func TestTuple(t *testing.T) {
+	user := tupleUser{ID: 7, Name: "alice", PrivilegeID: 1, PlayerID: 2}
+	arr, err := MarshalArray(&user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := SFSArray{int32(7), "alice", int16(1), int16(2)}
+	if !reflect.DeepEqual(arr, want) {
+		t.Fatalf("MarshalArray = %v, want %v", arr, want)
+	}
+
+	var out tupleUser
+	if err := UnmarshalArray(want, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, user) {
+		t.Fatalf("UnmarshalArray = %+v, want %+v", out, user)
+	}
+
+	// 嵌套在对象中的 tuple 结构体，经过 Pack/Unpack
+	vars := SFSArray{SFSArray{"level", byte(4), int32(3)}}
+	room := tupleRoom{
+		Name:  "lobby",
+		Users: []tupleUser{user, {ID: 8, Name: "bob", Vars: vars}},
+		Owner: &user,
+	}
+	obj, err := Marshal(room)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := obj["users"].(SFSArray)[1]; !reflect.DeepEqual(got, SFSArray{int32(8), "bob", int16(0), int16(0), vars}) {
+		t.Fatalf("users[1] = %v", got)
+	}
+	roundTrip(t, room, &tupleRoom{})
+
+	// 中间元素为 NULL 的 tuple
+	type nullableTuple struct {
+		Tuple
+		ID    int32            `sfs:",index=0"`
+		Alias Nullable[string] `sfs:",index=1"`
+		Seat  int16            `sfs:",index=2"`
+	}
+	type nullableRoom struct {
+		Users []nullableTuple `sfs:"users"`
+	}
+	nt := nullableRoom{Users: []nullableTuple{{ID: 3, Seat: 5}, {ID: 4, Alias: NullableOf("dan")}}}
+	obj, err = Marshal(nt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := obj["users"].(SFSArray)[0]; !reflect.DeepEqual(got, SFSArray{int32(3), nil, int16(5)}) {
+		t.Fatalf("users[0] = %v", got)
+	}
+	roundTrip(t, nt, &nullableRoom{})
+
+	err = UnmarshalOptions{ReportAll: true, DisallowUnknownFields: true}.UnmarshalArray(SFSArray{int32(1), "x", int16(0), int16(0), SFSArray{}, true}, &out)
+	if !errors.Is(err, ErrUnknownField) || !strings.Contains(err.Error(), "[5]") {
+		t.Fatalf("expected unknown element [5], got %v", err)
+	}
+	err = UnmarshalArray(SFSArray{int32(1), "x"}, &out)
+	var fe *FieldError
+	if !errors.As(err, &fe) || fe.Path != "[2]" || !errors.Is(err, ErrMissingField) {
+		t.Fatalf("expected missing [2], got %v", err)
+	}
+
+	if _, err := Marshal(user); err == nil {
+		t.Error("Marshal of a tuple struct should fail")
+	}
+	if err := Unmarshal(SFSObject{}, &out); err == nil {
+		t.Error("Unmarshal of SFSObject into a tuple struct should fail")
+	}
+
+	type badTuple struct {
+		Tuple
+		A int32 `sfs:",index=0,optional"`
+		B int32 `sfs:",index=1"`
+		C int32 `sfs:",index=3"`
+	}
+	type dupTuple struct {
+		Tuple
+		A int32 `sfs:",index=0"`
+		B int32 `sfs:",index=0"`
+	}
+	type noTuple struct {
+		A int32 `sfs:"a,index=0"`
+	}
+	for _, v := range []interface{}{badTuple{}, dupTuple{}, noTuple{}} {
+		if err := Precompile(v); err == nil {
+			t.Errorf("%T: expected error", v)
+		}
+	}
+}
@@ -1,5 +1,10 @@
 package sfs
 
+import (
+	"fmt"
+	"math"
+)
+
 type DataType byte
 
 const (
@@ -25,13 +30,70 @@ const (
 	TEXT             DataType = 20
 )
 
+// 协议限制
+const (
+	maxKeyLength    = 255            // SFS_OBJECT 的 key 字节数
+	maxStringLength = math.MaxUint16 // UTF_STRING 及 UTF_STRING_ARRAY 元素的字节数
+	maxCount        = math.MaxUint16 // SFS_OBJECT/SFS_ARRAY 及定长数组（BYTE_ARRAY 除外）的元素数量
+)
+
+var dataTypeNames = [...]string{
+	NULL:             "NULL",
+	BOOL:             "BOOL",
+	BYTE:             "BYTE",
+	SHORT:            "SHORT",
+	INT:              "INT",
+	LONG:             "LONG",
+	FLOAT:            "FLOAT",
+	DOUBLE:           "DOUBLE",
+	UTF_STRING:       "UTF_STRING",
+	BOOL_ARRAY:       "BOOL_ARRAY",
+	BYTE_ARRAY:       "BYTE_ARRAY",
+	SHORT_ARRAY:      "SHORT_ARRAY",
+	INT_ARRAY:        "INT_ARRAY",
+	LONG_ARRAY:       "LONG_ARRAY",
+	FLOAT_ARRAY:      "FLOAT_ARRAY",
+	DOUBLE_ARRAY:     "DOUBLE_ARRAY",
+	UTF_STRING_ARRAY: "UTF_STRING_ARRAY",
+	SFS_ARRAY:        "SFS_ARRAY",
+	SFS_OBJECT:       "SFS_OBJECT",
+	TEXT:             "TEXT",
+}
+
+func (t DataType) String() string {
+	if int(t) < len(dataTypeNames) && dataTypeNames[t] != "" {
+		return dataTypeNames[t]
+	}
+	return fmt.Sprintf("DataType(%d)", byte(t))
+}
+
 type SFSObject map[string]interface{}
 type SFSArray []interface{}
 
 type fieldInfo struct {
-	name     string
-	dataType DataType
-	optional bool
+	name           string
+	aliases        []string
+	dataType       DataType
+	optional       bool
+	payload        payloadFormat
+	payloadStrings StringEncoding // sfsbin 中字符串的编码方式（tag 选项 mutf8）
+	decimal        *decimalInfo
+	bits           *bitsInfo
+
+	discriminator string // discriminator= 的 key
+	pos           int    // tuple 中 index= 的下标，-1 表示没有
+
+	constraints []constraintOpt
+	unknown     []string // 无法识别的选项
 }
 
+// payloadFormat 是字段值先序列化后再以 BYTE_ARRAY/UTF_STRING 存放时使用的格式
+type payloadFormat byte
+
+const (
+	payloadNone   payloadFormat = iota
+	payloadJSON                 // tag 选项 json：encoding/json
+	payloadSFSBin               // tag 选项 sfsbin：打包后的 SFS_OBJECT（不含包头）
+)
+
 const tagName = "sfs"
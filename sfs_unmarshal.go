@@ -5,61 +5,176 @@ import (
 	"fmt"
 	"math"
 	"reflect"
+	"strconv"
+	"strings"
 )
 
+// UnmarshalOptions 控制 Unmarshal 的行为，零值即默认的严格模式
+type UnmarshalOptions struct {
+	// Coerce 开启宽松的数值转换：BYTE/SHORT/INT/LONG/FLOAT/DOUBLE 以及数字字符串
+	// 之间可以互相转换（含数组元素），超出目标类型范围或丢失小数部分时返回错误
+	Coerce bool
+
+	// DisallowUnknownFields 为 true 时，SFSObject 中没有对应结构体字段的 key 返回
+	// ErrUnknownField 错误（包括嵌套对象和 SFSArray 中的对象）
+	DisallowUnknownFields bool
+
+	// CaseInsensitive 为 true 时，key 和别名都找不到的情况下再按大小写不敏感匹配
+	CaseInsensitive bool
+
+	// ReportAll 为 true 时不在第一个错误处停止，而是收集所有未知 key、缺失 key、
+	// 转换错误和违反约束（min=、max= 等）的字段，以 *MultiError 一次返回
+	ReportAll bool
+
+	// InvalidUTF8 决定如何处理 sfsbin 字段中不合法的 UTF-8 字符串，默认原样保留
+	InvalidUTF8 InvalidUTF8Policy
+}
+
+type decoder struct {
+	opts UnmarshalOptions
+}
+
 func Unmarshal(data SFSObject, v interface{}) error {
+	return UnmarshalOptions{}.Unmarshal(data, v)
+}
+
+func (o UnmarshalOptions) Unmarshal(data SFSObject, v interface{}) error {
 	val := reflect.ValueOf(v)
 	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Struct {
 		return errors.New("must pass a pointer to a struct")
 	}
 
-	val = val.Elem()
-	typ := val.Type()
+	d := &decoder{opts: o}
+	return d.decodeStruct(data, val.Elem(), "")
+}
 
-	for i := 0; i < val.NumField(); i++ {
-		field := typ.Field(i)
-		fieldVal := val.Field(i)
+// decodeStruct 将 data 解码到结构体 val。
+// extra 不为空时是 DisallowUnknownFields 检查中额外允许的 key，例如 variant 的 discriminator。
+func (d *decoder) decodeStruct(data SFSObject, val reflect.Value, extra string) error {
+	plan, err := cachedPlan(val.Type())
+	if err != nil {
+		return err
+	}
+	if plan.tuple {
+		return fmt.Errorf("cannot convert SFSObject to %s, which embeds sfs.Tuple", val.Type())
+	}
 
-		if !fieldVal.CanSet() {
+	var errs []error
+	var folded map[string]string // CaseInsensitive 时按需构建
+	for i := range plan.fields {
+		fp := &plan.fields[i]
+		field := fp.value(val)
+
+		key, sfsValue, exists := d.lookup(data, fp, &folded)
+		if !exists {
+			if fp.optional {
+				continue
+			}
+			err := &FieldError{Path: fp.name, GoType: field.Type(), WireType: fp.dataType, Err: ErrMissingField}
+			if !d.opts.ReportAll {
+				return err
+			}
+			errs = appendError(errs, err)
 			continue
 		}
 
-		info, err := parseTag(field)
-		if err != nil {
-			return err
+		if err := fp.decodeChecked(d, field, sfsValue); err != nil {
+			err = prefixPath(err, key, field.Type(), wireTypeOf(sfsValue))
+			if !d.opts.ReportAll {
+				return err
+			}
+			errs = appendError(errs, err)
 		}
+	}
 
-		sfsValue, exists := data[info.name]
-		if !exists {
-			if info.optional {
+	if d.opts.DisallowUnknownFields {
+		for _, key := range sortedKeys(data) {
+			if _, ok := plan.byName[key]; ok || (extra != "" && key == extra) {
 				continue
 			}
-			return fmt.Errorf("required field %s not found", info.name)
+			if _, ok := plan.byFold[strings.ToLower(key)]; ok && d.opts.CaseInsensitive {
+				continue
+			}
+			err := &FieldError{Path: key, WireType: wireTypeOf(data[key]), Err: ErrUnknownField}
+			if !d.opts.ReportAll {
+				return err
+			}
+			errs = appendError(errs, err)
 		}
+	}
+
+	return joinErrors(errs)
+}
 
-		if err := convertFromSFSValue(fieldVal, sfsValue, info.dataType); err != nil {
-			return fmt.Errorf("field %s: %v", field.Name, err)
+// lookup 依次按 key、别名、（CaseInsensitive 时）大小写不敏感查找字段的值。
+// folded 是 data 的 foldKeys，第一次需要时构建，同一个对象的所有字段共用。
+func (d *decoder) lookup(data SFSObject, fp *fieldPlan, folded *map[string]string) (string, interface{}, bool) {
+	if v, ok := data[fp.name]; ok {
+		return fp.name, v, true
+	}
+	for _, alias := range fp.aliases {
+		if v, ok := data[alias]; ok {
+			return alias, v, true
 		}
 	}
+	if !d.opts.CaseInsensitive {
+		return "", nil, false
+	}
 
-	return nil
+	if *folded == nil {
+		*folded = foldKeys(data)
+	}
+	if key, ok := (*folded)[strings.ToLower(fp.name)]; ok {
+		return key, data[key], true
+	}
+	for _, alias := range fp.aliases {
+		if key, ok := (*folded)[strings.ToLower(alias)]; ok {
+			return key, data[key], true
+		}
+	}
+	return "", nil, false
+}
+
+// foldKeys 返回 小写 key -> key 的映射；多个 key 小写后相同时取字典序最小的一个
+func foldKeys(data SFSObject) map[string]string {
+	m := make(map[string]string, len(data))
+	for key := range data {
+		lower := strings.ToLower(key)
+		if prev, ok := m[lower]; !ok || key < prev {
+			m[lower] = key
+		}
+	}
+	return m
 }
 
-func convertFromSFSValue(field reflect.Value, sfsValue interface{}, dtype DataType) error {
+func (d *decoder) convertFromSFSValue(field reflect.Value, sfsValue interface{}, dtype DataType) error {
 	if sfsValue == nil {
 		field.Set(reflect.Zero(field.Type()))
 		return nil
 	}
 
+	// 注册了解码函数的类型
+	if fn, ok := lookupDecoder(field.Type()); ok {
+		return fn(sfsValue, field)
+	}
+
 	if dtype == NULL {
-		return autoConvert(field, sfsValue)
+		return d.autoConvert(field, sfsValue)
+	}
+
+	// 指针字段（如 *[]int32）按指向的类型转换
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			field.Set(reflect.New(field.Type().Elem()))
+		}
+		return d.convertFromSFSValue(field.Elem(), sfsValue, dtype)
 	}
 
 	if dtype >= BOOL_ARRAY && dtype <= UTF_STRING_ARRAY {
-		if field.Kind() != reflect.Slice {
+		if field.Kind() != reflect.Slice && field.Kind() != reflect.Array {
 			return fmt.Errorf("cannot convert array to non-slice type %s", field.Kind())
 		}
-		return convertArrayToField(field, sfsValue, dtype)
+		return d.convertArrayToField(field, sfsValue, dtype)
 	}
 
 	switch dtype {
@@ -70,23 +185,19 @@ func convertFromSFSValue(field reflect.Value, sfsValue interface{}, dtype DataTy
 		}
 	case BYTE:
 		if b, ok := sfsValue.(byte); ok {
-			field.SetUint(uint64(b))
-			return nil
+			return setByte(field, b)
 		}
 	case SHORT:
 		if s, ok := sfsValue.(int16); ok {
-			field.SetInt(int64(s))
-			return nil
+			return setInt(field, int64(s))
 		}
 	case INT:
 		if i, ok := sfsValue.(int32); ok {
-			field.SetInt(int64(i))
-			return nil
+			return setInt(field, int64(i))
 		}
 	case LONG:
 		if l, ok := sfsValue.(int64); ok {
-			field.SetInt(l)
-			return nil
+			return setInt(field, l)
 		}
 	case FLOAT:
 		if f, ok := sfsValue.(float32); ok {
@@ -106,32 +217,44 @@ func convertFromSFSValue(field reflect.Value, sfsValue interface{}, dtype DataTy
 	case SFS_OBJECT:
 		if obj, ok := sfsValue.(SFSObject); ok {
 			if field.Kind() == reflect.Struct {
-				if field.CanAddr() && field.Addr().CanInterface() {
-					return Unmarshal(obj, field.Addr().Interface())
-				}
-			} else if field.Kind() == reflect.Ptr {
+				return d.decodeStruct(obj, field, "")
+			} else if field.Kind() == reflect.Ptr && field.Type().Elem().Kind() == reflect.Struct {
 				if field.IsNil() {
 					field.Set(reflect.New(field.Type().Elem()))
 				}
-				return Unmarshal(obj, field.Interface())
+				return d.decodeStruct(obj, field.Elem(), "")
+			} else if field.Kind() == reflect.Map {
+				return d.convertObjectToMap(field, obj)
 			}
 		}
 	case SFS_ARRAY:
-		return convertSliceToField(field, sfsValue)
+		return d.convertSliceToField(field, sfsValue)
 	}
 
-	return fmt.Errorf("cannot convert %T to %s with type %d",
+	if d.opts.Coerce {
+		if ok, err := d.coerce(field, sfsValue); ok {
+			return err
+		}
+	}
+
+	return fmt.Errorf("cannot convert %T to %s with type %s",
 		sfsValue, field.Type(), dtype)
 }
 
-func convertArrayToField(field reflect.Value, sfsValue interface{}, dtype DataType) error {
+func (d *decoder) convertArrayToField(field reflect.Value, sfsValue interface{}, dtype DataType) error {
 	sliceType := field.Type()
 	elemType := sliceType.Elem()
+	if fn, ok := lookupDecoder(elemType); ok && isPrimitiveArray(sfsValue) {
+		return d.decodeRegisteredArray(field, reflect.ValueOf(sfsValue), fn)
+	}
 
 	switch dtype {
 	case BOOL_ARRAY:
 		if arr, ok := sfsValue.([]bool); ok {
-			slice := reflect.MakeSlice(sliceType, len(arr), len(arr))
+			slice, err := makeSeq(sliceType, len(arr))
+			if err != nil {
+				return err
+			}
 			for i, val := range arr {
 				slice.Index(i).SetBool(val)
 			}
@@ -141,13 +264,18 @@ func convertArrayToField(field reflect.Value, sfsValue interface{}, dtype DataTy
 
 	case BYTE_ARRAY:
 		if arr, ok := sfsValue.([]byte); ok {
-			if elemType.Kind() == reflect.Uint8 {
+			if elemType.Kind() == reflect.Uint8 && field.Kind() == reflect.Slice {
 				field.SetBytes(arr)
 				return nil
 			}
-			slice := reflect.MakeSlice(sliceType, len(arr), len(arr))
+			slice, err := makeSeq(sliceType, len(arr))
+			if err != nil {
+				return err
+			}
 			for i, val := range arr {
-				slice.Index(i).SetUint(uint64(val))
+				if err := setByte(slice.Index(i), val); err != nil {
+					return prefixPath(err, indexSeg(i), elemType, BYTE)
+				}
 			}
 			field.Set(slice)
 			return nil
@@ -155,9 +283,14 @@ func convertArrayToField(field reflect.Value, sfsValue interface{}, dtype DataTy
 
 	case SHORT_ARRAY:
 		if arr, ok := sfsValue.([]int16); ok {
-			slice := reflect.MakeSlice(sliceType, len(arr), len(arr))
+			slice, err := makeSeq(sliceType, len(arr))
+			if err != nil {
+				return err
+			}
 			for i, val := range arr {
-				slice.Index(i).SetInt(int64(val))
+				if err := setInt(slice.Index(i), int64(val)); err != nil {
+					return prefixPath(err, indexSeg(i), elemType, wireTypeOf(val))
+				}
 			}
 			field.Set(slice)
 			return nil
@@ -165,9 +298,14 @@ func convertArrayToField(field reflect.Value, sfsValue interface{}, dtype DataTy
 
 	case INT_ARRAY:
 		if arr, ok := sfsValue.([]int32); ok {
-			slice := reflect.MakeSlice(sliceType, len(arr), len(arr))
+			slice, err := makeSeq(sliceType, len(arr))
+			if err != nil {
+				return err
+			}
 			for i, val := range arr {
-				slice.Index(i).SetInt(int64(val))
+				if err := setInt(slice.Index(i), int64(val)); err != nil {
+					return prefixPath(err, indexSeg(i), elemType, wireTypeOf(val))
+				}
 			}
 			field.Set(slice)
 			return nil
@@ -175,9 +313,14 @@ func convertArrayToField(field reflect.Value, sfsValue interface{}, dtype DataTy
 
 	case LONG_ARRAY:
 		if arr, ok := sfsValue.([]int64); ok {
-			slice := reflect.MakeSlice(sliceType, len(arr), len(arr))
+			slice, err := makeSeq(sliceType, len(arr))
+			if err != nil {
+				return err
+			}
 			for i, val := range arr {
-				slice.Index(i).SetInt(val)
+				if err := setInt(slice.Index(i), val); err != nil {
+					return prefixPath(err, indexSeg(i), elemType, wireTypeOf(val))
+				}
 			}
 			field.Set(slice)
 			return nil
@@ -185,7 +328,10 @@ func convertArrayToField(field reflect.Value, sfsValue interface{}, dtype DataTy
 
 	case FLOAT_ARRAY:
 		if arr, ok := sfsValue.([]float32); ok {
-			slice := reflect.MakeSlice(sliceType, len(arr), len(arr))
+			slice, err := makeSeq(sliceType, len(arr))
+			if err != nil {
+				return err
+			}
 			for i, val := range arr {
 				slice.Index(i).SetFloat(float64(val))
 			}
@@ -195,7 +341,10 @@ func convertArrayToField(field reflect.Value, sfsValue interface{}, dtype DataTy
 
 	case DOUBLE_ARRAY:
 		if arr, ok := sfsValue.([]float64); ok {
-			slice := reflect.MakeSlice(sliceType, len(arr), len(arr))
+			slice, err := makeSeq(sliceType, len(arr))
+			if err != nil {
+				return err
+			}
 			for i, val := range arr {
 				slice.Index(i).SetFloat(val)
 			}
@@ -205,7 +354,10 @@ func convertArrayToField(field reflect.Value, sfsValue interface{}, dtype DataTy
 
 	case UTF_STRING_ARRAY:
 		if arr, ok := sfsValue.([]string); ok {
-			slice := reflect.MakeSlice(sliceType, len(arr), len(arr))
+			slice, err := makeSeq(sliceType, len(arr))
+			if err != nil {
+				return err
+			}
 			for i, val := range arr {
 				slice.Index(i).SetString(val)
 			}
@@ -215,23 +367,24 @@ func convertArrayToField(field reflect.Value, sfsValue interface{}, dtype DataTy
 
 	case SFS_ARRAY:
 		if arr, ok := sfsValue.(SFSArray); ok {
-			slice := reflect.MakeSlice(sliceType, len(arr), len(arr))
-			for i, val := range arr {
-				elem := reflect.New(elemType).Elem()
-				if err := autoConvert(elem, val); err != nil {
-					return err
-				}
-				slice.Index(i).Set(elem)
-			}
-			field.Set(slice)
-			return nil
+			return d.convertSFSArray(field, arr)
+		}
+	}
+
+	if d.opts.Coerce {
+		if ok, err := d.coerceSlice(field, sfsValue); ok {
+			return err
 		}
 	}
 
-	return fmt.Errorf("cannot convert %T to %s array type %d", sfsValue, elemType, dtype)
+	return fmt.Errorf("cannot convert %T to %s array type %s", sfsValue, elemType, dtype)
 }
 
-func autoConvert(field reflect.Value, sfsValue interface{}) error {
+func (d *decoder) autoConvert(field reflect.Value, sfsValue interface{}) error {
+	if fn, ok := lookupDecoder(field.Type()); ok {
+		return fn(sfsValue, field)
+	}
+
 	switch field.Kind() {
 	case reflect.Bool:
 		if b, ok := sfsValue.(bool); ok {
@@ -263,6 +416,10 @@ func autoConvert(field reflect.Value, sfsValue interface{}) error {
 				field.SetInt(int64(v))
 				return nil
 			}
+		case byte:
+			if field.Kind() == reflect.Int8 {
+				return setByte(field, v)
+			}
 		case int:
 			if field.Kind() == reflect.Int {
 				field.SetInt(int64(v))
@@ -281,6 +438,15 @@ func autoConvert(field reflect.Value, sfsValue interface{}) error {
 				field.SetUint(u)
 				return nil
 			}
+		case int32:
+			// uint16 映射为 INT，兼容 uint32/uint 的 INT 值
+			if field.Kind() == reflect.Uint16 || field.Kind() == reflect.Uint32 || field.Kind() == reflect.Uint {
+				return setInt(field, int64(v))
+			}
+		case int16:
+			if field.Kind() == reflect.Uint16 {
+				return setInt(field, int64(v))
+			}
 		case uint64:
 			if field.OverflowUint(v) {
 				return fmt.Errorf("value %d overflows %s", v, field.Type())
@@ -333,24 +499,32 @@ func autoConvert(field reflect.Value, sfsValue interface{}) error {
 		}
 
 	case reflect.Struct:
-		if obj, ok := sfsValue.(SFSObject); ok {
-			if field.CanAddr() && field.Addr().CanInterface() {
-				return Unmarshal(obj, field.Addr().Interface())
-			}
+		if wrapKindOf(field.Type()) != wrapNone {
+			return d.decodeWrapped(field, sfsValue, NULL)
+		}
+		switch v := sfsValue.(type) {
+		case SFSObject:
+			return d.decodeStruct(v, field, "")
+		case SFSArray:
+			return d.decodeTuple(v, field)
+		}
+
+	case reflect.Map:
+		switch obj := sfsValue.(type) {
+		case SFSObject:
+			return d.convertObjectToMap(field, obj)
+		case map[string]interface{}:
+			return d.convertObjectToMap(field, obj)
 		}
 
 	case reflect.Ptr:
 		if field.IsNil() {
 			field.Set(reflect.New(field.Type().Elem()))
 		}
-		return autoConvert(field.Elem(), sfsValue)
+		return d.autoConvert(field.Elem(), sfsValue)
 
-	case reflect.Slice:
-		// 处理 []interface{} 到具体切片类型的转换
-		if srcSlice, ok := sfsValue.([]interface{}); ok {
-			return convertSliceToField(field, srcSlice)
-		}
-		return convertSliceToField(field, sfsValue)
+	case reflect.Slice, reflect.Array:
+		return d.convertSliceToField(field, sfsValue)
 
 	case reflect.Interface:
 		// 如果目标字段是 interface{} 类型，直接设置值
@@ -360,41 +534,159 @@ func autoConvert(field reflect.Value, sfsValue interface{}) error {
 		}
 	}
 
+	if d.opts.Coerce {
+		if ok, err := d.coerce(field, sfsValue); ok {
+			return err
+		}
+	}
+
 	return fmt.Errorf("cannot auto-convert %T to %s", sfsValue, field.Type())
 }
 
-// convertInterfaceSliceToField 将 []interface{} 转换为目标切片类型
-// func convertInterfaceSliceToField(field reflect.Value, srcSlice []interface{}) error {
-// 	dstType := field.Type()
-// 	if dstType.Kind() != reflect.Slice {
-// 		return fmt.Errorf("target is not a slice type: %s", dstType)
-// 	}
-
-// 	elemType := dstType.Elem()
-// 	dstSlice := reflect.MakeSlice(dstType, len(srcSlice), len(srcSlice))
-
-// 	for i, elem := range srcSlice {
-// 		elemValue := reflect.New(elemType).Elem()
-// 		if err := autoConvert(elemValue, elem); err != nil {
-// 			return fmt.Errorf("element %d: %v", i, err)
-// 		}
-// 		dstSlice.Index(i).Set(elemValue)
-// 	}
-
-// 	field.Set(dstSlice)
-// 	return nil
-// }
-
-func convertSliceToField(field reflect.Value, sfsValue interface{}) error {
+// makeSeq 创建长度为 n 的切片；目标为 Go 数组时要求长度一致
+func makeSeq(typ reflect.Type, n int) (reflect.Value, error) {
+	if typ.Kind() == reflect.Array {
+		if typ.Len() != n {
+			return reflect.Value{}, fmt.Errorf("array length mismatch: got %d elements, want %d for %s", n, typ.Len(), typ)
+		}
+		return reflect.New(typ).Elem(), nil
+	}
+	return reflect.MakeSlice(typ, n, n), nil
+}
+
+// setInt 将 SFS 整数值写入有符号或无符号整数字段，超出范围时返回错误
+func setInt(field reflect.Value, n int64) error {
+	switch {
+	case isIntKind(field.Kind()):
+		if field.OverflowInt(n) {
+			return fmt.Errorf("value %d overflows %s", n, field.Type())
+		}
+		field.SetInt(n)
+	case isUintKind(field.Kind()):
+		if n < 0 || field.OverflowUint(uint64(n)) {
+			return fmt.Errorf("value %d overflows %s", n, field.Type())
+		}
+		field.SetUint(uint64(n))
+	default:
+		return fmt.Errorf("cannot convert integer to %s", field.Type())
+	}
+	return nil
+}
+
+// setByte 写入 BYTE 值，int8 字段按补码还原（与 Marshal 对称）
+func setByte(field reflect.Value, b byte) error {
+	if field.Kind() == reflect.Int8 {
+		field.SetInt(int64(int8(b)))
+		return nil
+	}
+	return setInt(field, int64(b))
+}
+
+// convertObjectToMap 将 SFSObject 转换为目标 map 类型，整数类型的 key 按十进制字符串解析
+func (d *decoder) convertObjectToMap(field reflect.Value, obj map[string]interface{}) error {
+	mapType := field.Type()
+	keyType := mapType.Key()
+	elemType := mapType.Elem()
+
+	var errs []error
+	m := reflect.MakeMapWithSize(mapType, len(obj))
+	for k, v := range obj {
+		key, err := parseMapKey(keyType, k)
+		if err != nil {
+			err = prefixPath(err, k, keyType, UTF_STRING)
+			if !d.opts.ReportAll {
+				return err
+			}
+			errs = appendError(errs, err)
+			continue
+		}
+
+		elem := reflect.New(elemType).Elem()
+		if err := d.convertFromSFSValue(elem, v, NULL); err != nil {
+			err = prefixPath(err, k, elemType, wireTypeOf(v))
+			if !d.opts.ReportAll {
+				return err
+			}
+			errs = appendError(errs, err)
+			continue
+		}
+		m.SetMapIndex(key, elem)
+	}
+
+	if len(errs) > 0 {
+		return joinErrors(errs)
+	}
+	field.Set(m)
+	return nil
+}
+
+// convertSFSArray 将 SFSArray 逐个元素转换为目标切片类型
+func (d *decoder) convertSFSArray(field reflect.Value, arr SFSArray) error {
+	sliceType := field.Type()
+	elemType := sliceType.Elem()
+
+	var errs []error
+	slice, err := makeSeq(sliceType, len(arr))
+	if err != nil {
+		return err
+	}
+	for i, val := range arr {
+		if err := d.convertFromSFSValue(slice.Index(i), val, NULL); err != nil {
+			err = prefixPath(err, indexSeg(i), elemType, wireTypeOf(val))
+			if !d.opts.ReportAll {
+				return err
+			}
+			errs = appendError(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return joinErrors(errs)
+	}
+	field.Set(slice)
+	return nil
+}
+
+// parseMapKey 将 SFSObject 的 key 转换为 map 的 key 类型
+func parseMapKey(keyType reflect.Type, s string) (reflect.Value, error) {
+	key := reflect.New(keyType).Elem()
+	switch {
+	case keyType.Kind() == reflect.String:
+		key.SetString(s)
+	case isIntKind(keyType.Kind()):
+		n, err := strconv.ParseInt(s, 10, keyType.Bits())
+		if err != nil {
+			return key, fmt.Errorf("invalid map key %q for %s", s, keyType)
+		}
+		key.SetInt(n)
+	case isUintKind(keyType.Kind()):
+		n, err := strconv.ParseUint(s, 10, keyType.Bits())
+		if err != nil {
+			return key, fmt.Errorf("invalid map key %q for %s", s, keyType)
+		}
+		key.SetUint(n)
+	default:
+		return key, fmt.Errorf("unsupported map key type: %s", keyType)
+	}
+	return key, nil
+}
+
+func (d *decoder) convertSliceToField(field reflect.Value, sfsValue interface{}) error {
 	sliceType := field.Type()
 	elemType := sliceType.Elem()
+	if fn, ok := lookupDecoder(elemType); ok && isPrimitiveArray(sfsValue) {
+		return d.decodeRegisteredArray(field, reflect.ValueOf(sfsValue), fn)
+	}
 
 	var slice reflect.Value
+	var err error
 
 	switch v := sfsValue.(type) {
 	case []bool:
 		if elemType.Kind() == reflect.Bool {
-			slice = reflect.MakeSlice(sliceType, len(v), len(v))
+			if slice, err = makeSeq(sliceType, len(v)); err != nil {
+				return err
+			}
 			for i, val := range v {
 				slice.Index(i).SetBool(val)
 			}
@@ -402,13 +694,25 @@ func convertSliceToField(field reflect.Value, sfsValue interface{}) error {
 			return nil
 		}
 	case []byte:
-		if elemType.Kind() == reflect.Uint8 {
+		if elemType.Kind() == reflect.Uint8 && field.Kind() == reflect.Slice {
 			field.SetBytes(v)
 			return nil
 		}
+		if elemType.Kind() == reflect.Int8 || elemType.Kind() == reflect.Uint8 {
+			if slice, err = makeSeq(sliceType, len(v)); err != nil {
+				return err
+			}
+			for i, val := range v {
+				setByte(slice.Index(i), val)
+			}
+			field.Set(slice)
+			return nil
+		}
 	case []int16:
 		if elemType.Kind() == reflect.Int16 {
-			slice = reflect.MakeSlice(sliceType, len(v), len(v))
+			if slice, err = makeSeq(sliceType, len(v)); err != nil {
+				return err
+			}
 			for i, val := range v {
 				slice.Index(i).SetInt(int64(val))
 			}
@@ -416,26 +720,37 @@ func convertSliceToField(field reflect.Value, sfsValue interface{}) error {
 			return nil
 		}
 	case []int32:
-		if elemType.Kind() == reflect.Int32 {
-			slice = reflect.MakeSlice(sliceType, len(v), len(v))
+		if elemType.Kind() == reflect.Int32 || elemType.Kind() == reflect.Uint16 {
+			if slice, err = makeSeq(sliceType, len(v)); err != nil {
+				return err
+			}
 			for i, val := range v {
-				slice.Index(i).SetInt(int64(val))
+				if err := setInt(slice.Index(i), int64(val)); err != nil {
+					return prefixPath(err, indexSeg(i), elemType, wireTypeOf(val))
+				}
 			}
 			field.Set(slice)
 			return nil
 		}
 	case []int64:
-		if elemType.Kind() == reflect.Int64 {
-			slice = reflect.MakeSlice(sliceType, len(v), len(v))
+		isInt := isIntKind(elemType.Kind()) || isUintKind(elemType.Kind())
+		if isInt && intWireType(elemType.Kind()) == LONG {
+			if slice, err = makeSeq(sliceType, len(v)); err != nil {
+				return err
+			}
 			for i, val := range v {
-				slice.Index(i).SetInt(val)
+				if err := setInt(slice.Index(i), val); err != nil {
+					return prefixPath(err, indexSeg(i), elemType, wireTypeOf(val))
+				}
 			}
 			field.Set(slice)
 			return nil
 		}
 	case []float32:
 		if elemType.Kind() == reflect.Float32 {
-			slice = reflect.MakeSlice(sliceType, len(v), len(v))
+			if slice, err = makeSeq(sliceType, len(v)); err != nil {
+				return err
+			}
 			for i, val := range v {
 				slice.Index(i).SetFloat(float64(val))
 			}
@@ -444,7 +759,9 @@ func convertSliceToField(field reflect.Value, sfsValue interface{}) error {
 		}
 	case []float64:
 		if elemType.Kind() == reflect.Float64 {
-			slice = reflect.MakeSlice(sliceType, len(v), len(v))
+			if slice, err = makeSeq(sliceType, len(v)); err != nil {
+				return err
+			}
 			for i, val := range v {
 				slice.Index(i).SetFloat(val)
 			}
@@ -453,7 +770,9 @@ func convertSliceToField(field reflect.Value, sfsValue interface{}) error {
 		}
 	case []string:
 		if elemType.Kind() == reflect.String {
-			slice = reflect.MakeSlice(sliceType, len(v), len(v))
+			if slice, err = makeSeq(sliceType, len(v)); err != nil {
+				return err
+			}
 			for i, val := range v {
 				slice.Index(i).SetString(val)
 			}
@@ -461,16 +780,16 @@ func convertSliceToField(field reflect.Value, sfsValue interface{}) error {
 			return nil
 		}
 	case SFSArray:
-		slice = reflect.MakeSlice(sliceType, len(v), len(v))
-		for i, val := range v {
-			elem := reflect.New(elemType).Elem()
-			if err := autoConvert(elem, val); err != nil {
-				return err
-			}
-			slice.Index(i).Set(elem)
+		return d.convertSFSArray(field, v)
+	case []interface{}:
+		// 处理 []interface{} 到具体切片类型的转换
+		return d.convertSFSArray(field, v)
+	}
+
+	if d.opts.Coerce {
+		if ok, err := d.coerceSlice(field, sfsValue); ok {
+			return err
 		}
-		field.Set(slice)
-		return nil
 	}
 
 	return fmt.Errorf("cannot convert %T to %s", sfsValue, sliceType)
@@ -0,0 +1,250 @@
+package sfs
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type coerceHolder struct {
+	Bet     int64     `sfs:"bet"`
+	Level   int32     `sfs:"level,type=INT"`
+	Rate    float64   `sfs:"rate"`
+	Count   uint16    `sfs:"count"`
+	Symbols []int64   `sfs:"symbols"`
+	Odds    []float32 `sfs:"odds"`
+}
+
+func TestUnmarshalCoerce(t *testing.T) {
+	obj := SFSObject{
+		"bet":     int32(100),
+		"level":   int16(3),
+		"rate":    "1.5",
+		"count":   int64(7),
+		"symbols": []int32{1, 2, 3},
+		"odds":    []float64{0.5, 2},
+	}
+
+	var out coerceHolder
+	if err := Unmarshal(obj, &out); err == nil {
+		t.Fatal("strict mode should reject INT for an int64 field")
+	}
+
+	if err := (UnmarshalOptions{Coerce: true}).Unmarshal(obj, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Bet != 100 || out.Level != 3 || out.Rate != 1.5 || out.Count != 7 ||
+		len(out.Symbols) != 3 || out.Symbols[2] != 3 || out.Odds[1] != 2 {
+		t.Fatalf("unexpected result: %+v", out)
+	}
+
+	for key, bad := range map[string]interface{}{
+		"level": int64(1) << 40,
+		"count": int32(-1),
+		"bet":   2.5,
+		// 整数无法精确表示为浮点数
+		"rate": int64(1)<<53 + 1,
+		"odds": []int32{1<<24 + 1},
+	} {
+		in := SFSObject{}
+		for k, v := range obj {
+			in[k] = v
+		}
+		in[key] = bad
+		if err := (UnmarshalOptions{Coerce: true}).Unmarshal(in, &out); err == nil {
+			t.Errorf("%s=%v: expected range error", key, bad)
+		}
+	}
+}
+
+type betRequest struct {
+	BetAmount int64  `sfs:"betAmount"`
+	Lines     int32  `sfs:"lines"`
+	Items     []Data `sfs:"items,optional"`
+}
+
+func TestUnmarshalUnknownAndMissing(t *testing.T) {
+	obj := SFSObject{
+		"betAmout": int64(100),
+		"lines":    int32(20),
+		"items":    SFSArray{SFSObject{"index": int32(1), "extra": true}, SFSObject{}},
+	}
+
+	var out betRequest
+	err := UnmarshalOptions{DisallowUnknownFields: true}.Unmarshal(obj, &out)
+	if !errors.Is(err, ErrMissingField) {
+		t.Fatalf("expected first error to be the missing betAmount, got %v", err)
+	}
+
+	err = UnmarshalOptions{DisallowUnknownFields: true, ReportAll: true}.Unmarshal(obj, &out)
+	var me *MultiError
+	if !errors.As(err, &me) {
+		t.Fatalf("expected MultiError, got %v", err)
+	}
+
+	got := map[string]error{}
+	for _, e := range me.Errors {
+		var fe *FieldError
+		if !errors.As(e, &fe) {
+			t.Fatalf("expected FieldError, got %v", e)
+		}
+		got[fe.Path] = fe.Err
+	}
+	want := map[string]error{
+		"betAmount":      ErrMissingField,
+		"betAmout":       ErrUnknownField,
+		"items[0].extra": ErrUnknownField,
+		"items[1].index": ErrMissingField,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected errors:\n got: %v\nwant: %v", got, want)
+	}
+}
+
+type gameFlow struct {
+	IsBoardEndFlag bool     `sfs:"isBoardEndFlag"`
+	Operations     []string `sfs:"permissionOperations,alias=ops|po"`
+}
+
+func TestUnmarshalAliases(t *testing.T) {
+	var out gameFlow
+	obj := SFSObject{"IsBoardEndFlag": true, "ops": []string{"baseGame"}}
+	if err := Unmarshal(obj, &out); !errors.Is(err, ErrMissingField) {
+		t.Fatalf("expected case-sensitive match to fail, got %v", err)
+	}
+
+	opts := UnmarshalOptions{CaseInsensitive: true, DisallowUnknownFields: true}
+	if err := opts.Unmarshal(obj, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !out.IsBoardEndFlag || len(out.Operations) != 1 {
+		t.Fatalf("unexpected result: %+v", out)
+	}
+
+	// 多个 key 只有大小写不同时，总是取字典序最小的一个
+	obj = SFSObject{"ISBOARDENDFLAG": false, "IsBoardEndFlag": true, "isboardendflag": true, "po": []string{}}
+	for i := 0; i < 10; i++ {
+		out = gameFlow{}
+		if err := (UnmarshalOptions{CaseInsensitive: true}).Unmarshal(obj, &out); err != nil {
+			t.Fatal(err)
+		}
+		if out.IsBoardEndFlag {
+			t.Fatalf("expected ISBOARDENDFLAG to win, got %+v", out)
+		}
+	}
+
+	rsp, err := Marshal(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := rsp["permissionOperations"]; !ok {
+		t.Fatalf("Marshal should emit the primary name: %v", rsp)
+	}
+
+	if err := Precompile(struct {
+		A int32 `sfs:"a"`
+		B int32 `sfs:"b,alias=a"`
+	}{}); err == nil {
+		t.Fatal("expected duplicate alias error")
+	}
+}
+
+type reelGrid struct {
+	Ready   [5]bool       `sfs:"readyHandFlag"`
+	Symbols [3][5]int32   `sfs:"screenSymbol"`
+	Damp    [][]int32     `sfs:"dampInfo"`
+	Hits    []*Data       `sfs:"hits"`
+	Lines   *[]int32      `sfs:"lines,type=INT_ARRAY"`
+	Light   *[][]bool     `sfs:"setLightFlag"`
+	Raw     [4]byte       `sfs:"raw"`
+	Names   [2]string     `sfs:"names,type=UTF_STRING_ARRAY"`
+	ByLevel map[int8]Data `sfs:"byLevel,optional"`
+}
+
+func TestUnmarshalArraysAndNestedSlices(t *testing.T) {
+	lines := []int32{1, 2}
+	light := [][]bool{{true, false}, {false, true}}
+	in := reelGrid{
+		Ready:   [5]bool{true, false, true, false, true},
+		Symbols: [3][5]int32{{6, 0, 3, 12, 6}, {8, 7, 3, 6, 11}, {8, 7, 0, 10, 11}},
+		Damp:    [][]int32{{9, 10, 3, 12, 7}, {5, 0, 4, 10, 6}},
+		Hits:    []*Data{{Index: 1}, {Index: 2}},
+		Lines:   &lines,
+		Light:   &light,
+		Raw:     [4]byte{1, 2, 3, 4},
+		Names:   [2]string{"a", "b"},
+	}
+	obj, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out reelGrid
+	if err := Unmarshal(obj, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("round trip mismatch:\n in: %+v\nout: %+v", in, out)
+	}
+
+	obj["readyHandFlag"] = []bool{true}
+	if err := Unmarshal(obj, &out); err == nil || !strings.Contains(err.Error(), "length mismatch") {
+		t.Fatalf("expected length mismatch error, got %v", err)
+	}
+}
+
+func TestGenericHelpers(t *testing.T) {
+	obj := SFSObject{
+		"code": int32(200),
+		"msg":  "success",
+		"data": SFSObject{"index": int32(1)},
+		"list": SFSArrayOf(int32(1), int32(2)),
+		"ids":  ArrayOf(int32(1), int32(2)),
+		"rows": SFSArrayOf(SFSObject{"id": int32(1)}),
+	}
+
+	rsp, err := UnmarshalAs[Respond](obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.Code != 200 || rsp.Data.Index != 1 {
+		t.Fatalf("unexpected result: %+v", rsp)
+	}
+
+	if code, ok := Get[int32](obj, "code"); !ok || code != 200 {
+		t.Fatalf("Get[int32] = %v, %v", code, ok)
+	}
+	if _, ok := Get[int64](obj, "code"); ok {
+		t.Fatal("Get[int64] should reject an INT value")
+	}
+	list, _ := Get[SFSArray](obj, "list")
+	if v, ok := GetAt[int32](list, 1); !ok || v != 2 {
+		t.Fatalf("GetAt[int32] = %v, %v", v, ok)
+	}
+	if _, ok := GetAt[int32](list, 2); ok {
+		t.Fatal("GetAt should reject an out of range index")
+	}
+
+	// ArrayOf 构造类型化数组，与 Marshal 编码 []int32 相同；SFSArrayOf 总是 SFS_ARRAY
+	for key, want := range map[string]DataType{"ids": INT_ARRAY, "list": SFS_ARRAY, "rows": SFS_ARRAY} {
+		if got := wireTypeOf(obj[key]); got != want {
+			t.Errorf("%s: wire type %s, want %s", key, got, want)
+		}
+	}
+	marshaled, err := Marshal(struct {
+		IDs []int32 `sfs:"ids"`
+	}{[]int32{1, 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	viaMarshal, _ := Pack(marshaled, PackOptions{})
+	viaArrayOf, _ := Pack(SFSObject{"ids": obj["ids"]}, PackOptions{})
+	if !bytes.Equal(viaMarshal, viaArrayOf) {
+		t.Fatalf("ArrayOf packs as %x, Marshal as %x", viaArrayOf, viaMarshal)
+	}
+	if names := ArrayOf("a", "b"); wireTypeOf(names) != UTF_STRING_ARRAY {
+		t.Fatalf("ArrayOf(strings) = %T", names)
+	}
+}
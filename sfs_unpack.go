@@ -2,253 +2,408 @@ package sfs
 
 import (
 	"bytes"
-	"compress/zlib"
 	"encoding/binary"
-	"errors"
 	"fmt"
 	"io"
+	"math"
+	"sync/atomic"
 )
 
+// UnpackOptions 控制 Unpack 的解码方式
+type UnpackOptions struct {
+	// MaxSize 限制（解压后）对象数据的字节数，超出时返回 ErrLimitExceeded；0 表示不限制
+	MaxSize int
+	// Strings 是 key、UTF_STRING 和 UTF_STRING_ARRAY 的编码方式
+	Strings StringEncoding
+	// InvalidUTF8 决定如何处理不合法的 UTF-8 字符串（包括 TEXT），默认原样保留
+	InvalidUTF8 InvalidUTF8Policy
+}
+
+// Unpack 解码一个完整的数据包，可以被多个 goroutine 同时调用。
+// 返回的值不引用 data。SFSObject 和 SFSArray 中的 NULL 解码为 nil。
+func Unpack(data []byte, opts UnpackOptions) (interface{}, error) {
+	u := Unpacker{data: data, opts: opts}
+	return u.unpack()
+}
+
+// Unpacker 解码一个数据包，可以用 Reset 复用。
+// 同一个 Unpacker 不能被多个 goroutine 同时使用，否则返回 ErrConcurrentUse；
+// 需要并发时使用包级别的 Unpack。
 type Unpacker struct {
-	buf *bytes.Buffer
+	data []byte
+	pos  int
+	opts UnpackOptions
+	busy atomic.Bool
 }
 
 func NewUnpacker(data []byte) *Unpacker {
-	return &Unpacker{buf: bytes.NewBuffer(data)}
+	return &Unpacker{data: data}
+}
+
+// SetOptions 设置之后的 Unpack 使用的选项；与 Unpack 同时调用时返回 ErrConcurrentUse
+func (u *Unpacker) SetOptions(opts UnpackOptions) error {
+	if !u.busy.CompareAndSwap(false, true) {
+		return ErrConcurrentUse
+	}
+	defer u.busy.Store(false)
+	u.opts = opts
+	return nil
+}
+
+// Reset 让 Unpacker 改为解码 data；与 Unpack 同时调用时返回 ErrConcurrentUse
+func (u *Unpacker) Reset(data []byte) error {
+	if !u.busy.CompareAndSwap(false, true) {
+		return ErrConcurrentUse
+	}
+	defer u.busy.Store(false)
+	u.data, u.pos = data, 0
+	return nil
+}
+
+// offset 返回当前读取位置在数据中的字节偏移
+func (u *Unpacker) offset() int {
+	return u.pos
+}
+
+// errorAt 将错误包装为 DecodeError，嵌套值已包装的错误原样返回，
+// 数组元素的错误（带有下标路径的 FieldError）保留其路径
+func (u *Unpacker) errorAt(offset int, dataType DataType, err error) error {
+	switch e := err.(type) {
+	case *DecodeError:
+		return err
+	case *FieldError:
+		return &DecodeError{Offset: offset, Path: e.Path, Type: dataType, Err: truncated(e.Err)}
+	}
+	return &DecodeError{Offset: offset, Type: dataType, Err: truncated(err)}
 }
 
 func (u *Unpacker) Unpack() (interface{}, error) {
-	firstByte, err := u.buf.ReadByte()
+	if !u.busy.CompareAndSwap(false, true) {
+		return nil, ErrConcurrentUse
+	}
+	defer u.busy.Store(false)
+	return u.unpack()
+}
+
+func (u *Unpacker) unpack() (interface{}, error) {
+	data, compressed, err := parseHeader(u.data[u.pos:])
 	if err != nil {
 		return nil, err
 	}
+	if !compressed {
+		if u.opts.MaxSize > 0 && len(data) > u.opts.MaxSize {
+			return nil, fmt.Errorf("%w: data size %d", ErrLimitExceeded, len(data))
+		}
+		u.data, u.pos = data, 0
+		return u.decodeValue()
+	}
+
+	// 解码出的值不引用数据缓冲区，解压缓冲区用完即可放回池中
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := inflate(buf, data, u.opts.MaxSize); err != nil {
+		return nil, err
+	}
+	u.data, u.pos = buf.Bytes(), 0
+	defer func() { u.data = nil }()
+	return u.decodeValue()
+}
+
+// parseHeader 解析数据包头（标志字节 + 2/4 字节长度），返回对象数据及是否压缩
+func parseHeader(packet []byte) ([]byte, bool, error) {
+	if len(packet) < 1 {
+		return nil, false, &DecodeError{Offset: 0, Type: NULL, Err: ErrTruncated}
+	}
 
+	firstByte := packet[0]
 	compressed := (firstByte & 32) > 0
 	lengthIn4Bytes := (firstByte & 8) > 0
 
-	var dataLength uint32
+	var dataLength, pos int
 	if lengthIn4Bytes {
-		if err := binary.Read(u.buf, binary.BigEndian, &dataLength); err != nil {
-			return nil, err
+		if len(packet) < 5 {
+			return nil, false, &DecodeError{Offset: 1, Type: NULL, Err: ErrTruncated}
 		}
+		dataLength, pos = int(binary.BigEndian.Uint32(packet[1:])), 5
 	} else {
-		var length uint16
-		if err := binary.Read(u.buf, binary.BigEndian, &length); err != nil {
-			return nil, err
+		if len(packet) < 3 {
+			return nil, false, &DecodeError{Offset: 1, Type: NULL, Err: ErrTruncated}
 		}
-		dataLength = uint32(length)
+		dataLength, pos = int(binary.BigEndian.Uint16(packet[1:])), 3
 	}
 
-	data := make([]byte, dataLength)
-	if _, err := io.ReadFull(u.buf, data); err != nil {
+	if len(packet)-pos < dataLength {
+		return nil, false, &DecodeError{Offset: pos, Type: NULL, Err: ErrTruncated}
+	}
+	return packet[pos : pos+dataLength], compressed, nil
+}
+
+// splitPacket 解析数据包头，返回对象数据，压缩时解压到新的缓冲区
+func splitPacket(packet []byte) ([]byte, error) {
+	data, compressed, err := parseHeader(packet)
+	if err != nil || !compressed {
+		return data, err
+	}
+
+	var decompressed bytes.Buffer
+	if err := inflate(&decompressed, data, 0); err != nil {
 		return nil, err
 	}
+	return decompressed.Bytes(), nil
+}
 
-	if compressed {
-		r, err := zlib.NewReader(bytes.NewReader(data))
-		if err != nil {
-			return nil, err
-		}
-		defer r.Close()
+// next 返回接下来的 n 个字节并前移读取位置，数据不足时返回 io.ErrUnexpectedEOF
+func (u *Unpacker) next(n int) ([]byte, error) {
+	if n > len(u.data)-u.pos {
+		u.pos = len(u.data)
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := u.data[u.pos : u.pos+n]
+	u.pos += n
+	return b, nil
+}
 
-		var decompressed bytes.Buffer
-		if _, err := io.Copy(&decompressed, r); err != nil {
-			return nil, err
-		}
-		data = decompressed.Bytes()
+func (u *Unpacker) readByte() (byte, error) {
+	if u.pos >= len(u.data) {
+		return 0, io.EOF
 	}
+	b := u.data[u.pos]
+	u.pos++
+	return b, nil
+}
 
-	u.buf = bytes.NewBuffer(data)
-	return u.decodeValue()
+func (u *Unpacker) readUint16() (uint16, error) {
+	b, err := u.next(2)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint16(b), nil
+}
+
+func (u *Unpacker) readUint32() (uint32, error) {
+	b, err := u.next(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(b), nil
+}
+
+func (u *Unpacker) readUint64() (uint64, error) {
+	b, err := u.next(8)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(b), nil
+}
+
+// decodeString 按 Strings 和 InvalidUTF8 选项转换字符串
+func (u *Unpacker) decodeString(b []byte) (string, error) {
+	return decodeString(b, u.opts.Strings, u.opts.InvalidUTF8)
+}
+
+// readArray 读取定长数组的 2 字节元素数量和全部元素数据
+func (u *Unpacker) readArray(typ DataType) (int, []byte, error) {
+	n, err := u.readUint16()
+	if err != nil {
+		return 0, nil, err
+	}
+	b, err := u.next(int(n) * elemSize(typ))
+	return int(n), b, err
 }
 
 func (u *Unpacker) decodeValue() (interface{}, error) {
-	typeByte, err := u.buf.ReadByte()
+	start := u.offset()
+	typeByte, err := u.readByte()
 	if err != nil {
-		return nil, err
+		return nil, u.errorAt(start, NULL, err)
 	}
 
 	dataType := DataType(typeByte)
+	value, err := u.decodeData(dataType)
+	if err != nil {
+		return nil, u.errorAt(start, dataType, err)
+	}
+	return value, nil
+}
 
+func (u *Unpacker) decodeData(dataType DataType) (interface{}, error) {
 	switch dataType {
 	case NULL:
 		return nil, nil
 	case BOOL:
-		val, err := u.buf.ReadByte()
+		val, err := u.readByte()
 		if err != nil {
 			return nil, err
 		}
 		return val != 0, nil
 	case BYTE:
-		return u.buf.ReadByte()
+		return u.readByte()
 	case SHORT:
-		var val int16
-		if err := binary.Read(u.buf, binary.BigEndian, &val); err != nil {
+		val, err := u.readUint16()
+		if err != nil {
 			return nil, err
 		}
-		return val, nil
+		return int16(val), nil
 	case INT:
-		var val int32
-		if err := binary.Read(u.buf, binary.BigEndian, &val); err != nil {
+		val, err := u.readUint32()
+		if err != nil {
 			return nil, err
 		}
-		return val, nil
+		return int32(val), nil
 	case LONG:
-		var val int64
-		if err := binary.Read(u.buf, binary.BigEndian, &val); err != nil {
+		val, err := u.readUint64()
+		if err != nil {
 			return nil, err
 		}
-		return val, nil
+		return int64(val), nil
 	case FLOAT:
-		var val float32
-		if err := binary.Read(u.buf, binary.BigEndian, &val); err != nil {
+		val, err := u.readUint32()
+		if err != nil {
 			return nil, err
 		}
-		return val, nil
+		return math.Float32frombits(val), nil
 	case DOUBLE:
-		var val float64
-		if err := binary.Read(u.buf, binary.BigEndian, &val); err != nil {
+		val, err := u.readUint64()
+		if err != nil {
 			return nil, err
 		}
-		return val, nil
+		return math.Float64frombits(val), nil
 	case UTF_STRING:
-		var length uint16
-		if err := binary.Read(u.buf, binary.BigEndian, &length); err != nil {
+		length, err := u.readUint16()
+		if err != nil {
 			return nil, err
 		}
-		strBytes := make([]byte, length)
-		if _, err := io.ReadFull(u.buf, strBytes); err != nil {
+		strBytes, err := u.next(int(length))
+		if err != nil {
 			return nil, err
 		}
-		return string(strBytes), nil
+		return u.decodeString(strBytes)
 	case BOOL_ARRAY:
-		var size uint16
-		if err := binary.Read(u.buf, binary.BigEndian, &size); err != nil {
+		n, data, err := u.readArray(dataType)
+		if err != nil {
 			return nil, err
 		}
-		arr := make([]bool, size)
-		for i := uint16(0); i < size; i++ {
-			val, err := u.buf.ReadByte()
-			if err != nil {
-				return nil, err
-			}
-			arr[i] = val != 0
+		arr := make([]bool, n)
+		for i := range arr {
+			arr[i] = data[i] != 0
 		}
 		return arr, nil
 	case BYTE_ARRAY:
-		var size uint32
-		if err := binary.Read(u.buf, binary.BigEndian, &size); err != nil {
+		size, err := u.readUint32()
+		if err != nil {
 			return nil, err
 		}
-		arr := make([]byte, size)
-		if _, err := io.ReadFull(u.buf, arr); err != nil {
+		data, err := u.next(int(size))
+		if err != nil {
 			return nil, err
 		}
-		return arr, nil
+		return append([]byte(nil), data...), nil
 	case SHORT_ARRAY:
-		var size uint16
-		if err := binary.Read(u.buf, binary.BigEndian, &size); err != nil {
+		n, data, err := u.readArray(dataType)
+		if err != nil {
 			return nil, err
 		}
-		arr := make([]int16, size)
-		if err := binary.Read(u.buf, binary.BigEndian, &arr); err != nil {
-			return nil, err
+		arr := make([]int16, n)
+		for i := range arr {
+			arr[i] = int16(binary.BigEndian.Uint16(data[i*2:]))
 		}
 		return arr, nil
 	case INT_ARRAY:
-		var size uint16
-		if err := binary.Read(u.buf, binary.BigEndian, &size); err != nil {
+		n, data, err := u.readArray(dataType)
+		if err != nil {
 			return nil, err
 		}
-		arr := make([]int32, size)
-		if err := binary.Read(u.buf, binary.BigEndian, &arr); err != nil {
-			return nil, err
+		arr := make([]int32, n)
+		for i := range arr {
+			arr[i] = int32(binary.BigEndian.Uint32(data[i*4:]))
 		}
 		return arr, nil
 	case LONG_ARRAY:
-		var size uint32
-		if err := binary.Read(u.buf, binary.BigEndian, &size); err != nil {
+		n, data, err := u.readArray(dataType)
+		if err != nil {
 			return nil, err
 		}
-		arr := make([]int64, size)
-		if err := binary.Read(u.buf, binary.BigEndian, &arr); err != nil {
-			return nil, err
+		arr := make([]int64, n)
+		for i := range arr {
+			arr[i] = int64(binary.BigEndian.Uint64(data[i*8:]))
 		}
 		return arr, nil
 	case FLOAT_ARRAY:
-		var size uint16
-		if err := binary.Read(u.buf, binary.BigEndian, &size); err != nil {
+		n, data, err := u.readArray(dataType)
+		if err != nil {
 			return nil, err
 		}
-		arr := make([]float32, size)
-		if err := binary.Read(u.buf, binary.BigEndian, &arr); err != nil {
-			return nil, err
+		arr := make([]float32, n)
+		for i := range arr {
+			arr[i] = math.Float32frombits(binary.BigEndian.Uint32(data[i*4:]))
 		}
 		return arr, nil
 	case DOUBLE_ARRAY:
-		var size uint16
-		if err := binary.Read(u.buf, binary.BigEndian, &size); err != nil {
+		n, data, err := u.readArray(dataType)
+		if err != nil {
 			return nil, err
 		}
-		arr := make([]float64, size)
-		if err := binary.Read(u.buf, binary.BigEndian, &arr); err != nil {
-			return nil, err
+		arr := make([]float64, n)
+		for i := range arr {
+			arr[i] = math.Float64frombits(binary.BigEndian.Uint64(data[i*8:]))
 		}
 		return arr, nil
 	case UTF_STRING_ARRAY:
-		var size uint16
-		if err := binary.Read(u.buf, binary.BigEndian, &size); err != nil {
+		size, err := u.readUint16()
+		if err != nil {
 			return nil, err
 		}
 		arr := make([]string, size)
-		for i := uint16(0); i < size; i++ {
-			var length uint16
-			if err := binary.Read(u.buf, binary.BigEndian, &length); err != nil {
+		for i := range arr {
+			length, err := u.readUint16()
+			if err != nil {
 				return nil, err
 			}
-			strBytes := make([]byte, length)
-			if _, err := io.ReadFull(u.buf, strBytes); err != nil {
+			strBytes, err := u.next(int(length))
+			if err != nil {
 				return nil, err
 			}
-			arr[i] = string(strBytes)
+			if arr[i], err = u.decodeString(strBytes); err != nil {
+				return nil, prefixPath(err, indexSeg(i), nil, UTF_STRING_ARRAY)
+			}
 		}
 		return arr, nil
 	case SFS_OBJECT:
-		var count uint16
-		if err := binary.Read(u.buf, binary.BigEndian, &count); err != nil {
+		count, err := u.readUint16()
+		if err != nil {
 			return nil, err
 		}
 
-		obj := make(SFSObject)
+		obj := make(SFSObject, count)
 		for i := uint16(0); i < count; i++ {
-			var keyLen uint16
-			if err := binary.Read(u.buf, binary.BigEndian, &keyLen); err != nil {
+			keyLen, err := u.readUint16()
+			if err != nil {
 				return nil, err
 			}
-			if keyLen < 0 || keyLen > 255 {
-				return nil, errors.New("invalid SFSObject key length")
+			if keyLen > maxKeyLength {
+				return nil, fmt.Errorf("%w: key length %d", ErrLimitExceeded, keyLen)
 			}
 
-			keyBytes := make([]byte, keyLen)
-			if _, err := io.ReadFull(u.buf, keyBytes); err != nil {
+			keyBytes, err := u.next(int(keyLen))
+			if err != nil {
+				return nil, err
+			}
+			key, err := u.decodeString(keyBytes)
+			if err != nil {
 				return nil, err
 			}
-			key := string(keyBytes)
 
 			value, err := u.decodeValue()
 			if err != nil {
-				return nil, fmt.Errorf("could not decode value for key: %s, error: %v", key, err)
-			}
-			if value == nil {
-				return nil, fmt.Errorf("could not decode value for key: %s", key)
+				return nil, prefixPath(err, key, nil, NULL)
 			}
 
 			obj[key] = value
 		}
 		return obj, nil
 	case SFS_ARRAY:
-		var count uint16
-		if err := binary.Read(u.buf, binary.BigEndian, &count); err != nil {
+		count, err := u.readUint16()
+		if err != nil {
 			return nil, err
 		}
 
@@ -256,26 +411,24 @@ func (u *Unpacker) decodeValue() (interface{}, error) {
 		for i := uint16(0); i < count; i++ {
 			value, err := u.decodeValue()
 			if err != nil {
-				return nil, fmt.Errorf("could not decode value for index: %d, error: %v", i, err)
-			}
-			if value == nil {
-				return nil, fmt.Errorf("could not decode value for index: %d", i)
+				return nil, prefixPath(err, indexSeg(int(i)), nil, NULL)
 			}
 
 			arr[i] = value
 		}
 		return arr, nil
 	case TEXT:
-		var length uint32
-		if err := binary.Read(u.buf, binary.BigEndian, &length); err != nil {
+		length, err := u.readUint32()
+		if err != nil {
 			return nil, err
 		}
-		strBytes := make([]byte, length)
-		if _, err := io.ReadFull(u.buf, strBytes); err != nil {
+		strBytes, err := u.next(int(length))
+		if err != nil {
 			return nil, err
 		}
-		return string(strBytes), nil
+		// TEXT 总是标准 UTF-8
+		return decodeString(strBytes, StandardUTF8, u.opts.InvalidUTF8)
 	default:
-		return nil, fmt.Errorf("unknown data type: %d", dataType)
+		return nil, ErrUnknownType
 	}
 }
@@ -3,8 +3,12 @@ package sfs
 import (
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"reflect"
+	"strings"
+	"sync"
 
 	"testing"
 )
@@ -108,3 +112,149 @@ func TestPackByStruct(t *testing.T) {
 	// }
 
 }
+
+func TestDecodeErrors(t *testing.T) {
+	obj := SFSObject{"p": SFSObject{"list": SFSArray{int32(1), "two"}}}
+	data, err := NewPacker().Pack(obj, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewUnpacker(data[:len(data)-2]).Unpack()
+	if !errors.Is(err, ErrTruncated) {
+		t.Fatalf("expected ErrTruncated, got %v", err)
+	}
+
+	// 将最后一个值（字符串 "two"）的类型字节改为未知类型
+	body := append([]byte(nil), data...)
+	body[len(body)-6] = 30
+	_, err = NewUnpacker(body).Unpack()
+	var de *DecodeError
+	if !errors.As(err, &de) || !errors.Is(err, ErrUnknownType) {
+		t.Fatalf("expected DecodeError with ErrUnknownType, got %v", err)
+	}
+	if de.Path != "p.list[1]" || de.Offset != len(body)-6-3 {
+		t.Fatalf("unexpected path/offset: %q %d", de.Path, de.Offset)
+	}
+}
+
+func TestFieldErrorPath(t *testing.T) {
+	type hit struct {
+		HitOdds int32 `sfs:"hitOdds"`
+	}
+	type result struct {
+		Ways []hit `sfs:"waysResult"`
+	}
+
+	obj := SFSObject{"waysResult": SFSArray{
+		SFSObject{"hitOdds": int32(1)},
+		SFSObject{"hitOdds": "x"},
+	}}
+	var out result
+	err := Unmarshal(obj, &out)
+	var fe *FieldError
+	if !errors.As(err, &fe) {
+		t.Fatalf("expected FieldError, got %v", err)
+	}
+	if fe.Path != "waysResult[1].hitOdds" || fe.WireType != UTF_STRING || fe.GoType.Kind() != reflect.Int32 {
+		t.Fatalf("unexpected field error: %+v", fe)
+	}
+
+	// UTF_STRING_ARRAY 中的字符串错误同样带有下标
+	long := strings.Repeat("x", maxStringLength+1)
+	_, err = Pack(SFSObject{"p": SFSObject{"names": []string{"a", long}}}, PackOptions{})
+	if !errors.As(err, &fe) || fe.Path != "p.names[1]" {
+		t.Fatalf("expected FieldError at p.names[1], got %v", err)
+	}
+	data, err := Pack(SFSObject{"p": SFSObject{"names": []string{"a", "b\xff"}}}, PackOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Unpack(data, UnpackOptions{InvalidUTF8: InvalidUTF8Reject})
+	var de *DecodeError
+	if !errors.As(err, &de) || de.Path != "p.names[1]" || de.Type != UTF_STRING_ARRAY {
+		t.Fatalf("expected DecodeError at p.names[1], got %v", err)
+	}
+}
+
+func TestPackStateless(t *testing.T) {
+	obj := SFSObject{"c": "h5.spinResponse", "p": SFSObject{"list": SFSArray{int32(1), "two"}}}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(compress bool) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				data, err := Pack(obj, PackOptions{Compress: compress})
+				if err == nil {
+					var v interface{}
+					v, err = Unpack(data, UnpackOptions{})
+					if err == nil && !reflect.DeepEqual(v, obj) {
+						err = fmt.Errorf("round trip mismatch: %v", v)
+					}
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(i%2 == 0)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	// Packer.Pack 的结果不会被之后的调用修改
+	p := NewPacker()
+	first, _ := p.Pack(SFSObject{"a": int32(1)}, false)
+	saved := append([]byte(nil), first...)
+	p.Pack(SFSObject{"b": int32(2)}, false)
+	if !reflect.DeepEqual(first, saved) {
+		t.Fatal("Pack output was modified by a later call")
+	}
+
+	p.busy.Store(true)
+	if _, err := p.Pack(obj, false); !errors.Is(err, ErrConcurrentUse) {
+		t.Fatalf("expected ErrConcurrentUse, got %v", err)
+	}
+	if err := p.SetStrings(ModifiedUTF8); !errors.Is(err, ErrConcurrentUse) {
+		t.Fatalf("SetStrings: expected ErrConcurrentUse, got %v", err)
+	}
+	if err := p.Reset(); !errors.Is(err, ErrConcurrentUse) {
+		t.Fatalf("Reset: expected ErrConcurrentUse, got %v", err)
+	}
+
+	data, _ := Pack(obj, PackOptions{Compress: true})
+	if _, err := Unpack(data, UnpackOptions{MaxSize: 10}); !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("expected ErrLimitExceeded, got %v", err)
+	}
+
+	u := NewUnpacker(saved)
+	u.Unpack()
+	if err := u.Reset(data); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := u.Unpack(); err != nil || !reflect.DeepEqual(v, obj) {
+		t.Fatalf("Unpack after Reset: %v %v", v, err)
+	}
+
+	// 与 Unpack 同时调用的 Reset/SetOptions 不能被悄悄丢弃
+	u.busy.Store(true)
+	if err := u.Reset(saved); !errors.Is(err, ErrConcurrentUse) {
+		t.Fatalf("Reset: expected ErrConcurrentUse, got %v", err)
+	}
+	if err := u.SetOptions(UnpackOptions{MaxSize: 1}); !errors.Is(err, ErrConcurrentUse) {
+		t.Fatalf("SetOptions: expected ErrConcurrentUse, got %v", err)
+	}
+	u.busy.Store(false)
+	if err := u.Reset(data); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := u.Unpack(); err != nil || !reflect.DeepEqual(v, obj) {
+		t.Fatalf("Unpack after rejected Reset: %v %v", v, err)
+	}
+}
@@ -0,0 +1,127 @@
+package sfs
+
+import (
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// StringEncoding 是 key、UTF_STRING 和 UTF_STRING_ARRAY 在线上的编码方式
+type StringEncoding byte
+
+const (
+	// StandardUTF8 是标准 UTF-8
+	StandardUTF8 StringEncoding = iota
+	// ModifiedUTF8 是 Java DataOutputStream.writeUTF 使用的 modified UTF-8：
+	// U+0000 编码为 C0 80，U+FFFF 以上的字符（如 emoji）编码为两个 3 字节的代理项（CESU-8）
+	ModifiedUTF8
+)
+
+// InvalidUTF8Policy 决定解码时如何处理不合法的 UTF-8 字符串
+type InvalidUTF8Policy byte
+
+const (
+	// InvalidUTF8Pass 原样保留字节
+	InvalidUTF8Pass InvalidUTF8Policy = iota
+	// InvalidUTF8Reject 返回 ErrInvalidUTF8
+	InvalidUTF8Reject
+	// InvalidUTF8Replace 将不合法的字节替换为 U+FFFD
+	InvalidUTF8Replace
+)
+
+// modifiedUTF8Len 返回 s 按 modified UTF-8 编码后的字节数
+func modifiedUTF8Len(s string) int {
+	n := 0
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			n++ // 不合法的字节原样写出
+		case r == 0:
+			n += 2
+		case r > 0xFFFF:
+			n += 6
+		default:
+			n += size
+		}
+		i += size
+	}
+	return n
+}
+
+// appendModifiedUTF8 将 s 按 modified UTF-8 编码追加到 b，不合法的字节原样写出
+func appendModifiedUTF8(b []byte, s string) []byte {
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			b = append(b, s[i])
+		case r == 0:
+			b = append(b, 0xC0, 0x80)
+		case r > 0xFFFF:
+			hi, lo := utf16.EncodeRune(r)
+			b = appendSurrogate(b, hi)
+			b = appendSurrogate(b, lo)
+		default:
+			b = append(b, s[i:i+size]...)
+		}
+		i += size
+	}
+	return b
+}
+
+// appendSurrogate 将 UTF-16 代理项按 3 字节的 UTF-8 形式追加到 b
+func appendSurrogate(b []byte, r rune) []byte {
+	return append(b, 0xE0|byte(r>>12), 0x80|byte(r>>6)&0x3F, 0x80|byte(r)&0x3F)
+}
+
+// fromModifiedUTF8 将 modified UTF-8 转换为标准 UTF-8。
+// 没有 C0 80 和代理项时直接返回 b；落单的代理项保留原样，由 InvalidUTF8Policy 处理。
+func fromModifiedUTF8(b []byte) []byte {
+	i := 0
+	for i < len(b) && b[i] != 0xC0 && b[i] != 0xED {
+		i++
+	}
+	if i == len(b) {
+		return b
+	}
+
+	out := make([]byte, i, len(b))
+	copy(out, b[:i])
+	for i < len(b) {
+		c := b[i]
+		if c == 0xC0 && i+1 < len(b) && b[i+1] == 0x80 {
+			out = append(out, 0)
+			i += 2
+			continue
+		}
+		if c == 0xED && i+5 < len(b) && b[i+1]&0xF0 == 0xA0 && b[i+3] == 0xED && b[i+4]&0xF0 == 0xB0 {
+			hi := rune(c&0x0F)<<12 | rune(b[i+1]&0x3F)<<6 | rune(b[i+2]&0x3F)
+			lo := rune(b[i+3]&0x0F)<<12 | rune(b[i+4]&0x3F)<<6 | rune(b[i+5]&0x3F)
+			out = utf8.AppendRune(out, utf16.DecodeRune(hi, lo))
+			i += 6
+			continue
+		}
+		out = append(out, c)
+		i++
+	}
+	return out
+}
+
+// decodeString 按选项将线上的字符串字节转换为 Go 字符串
+func decodeString(b []byte, encoding StringEncoding, policy InvalidUTF8Policy) (string, error) {
+	if encoding == ModifiedUTF8 {
+		b = fromModifiedUTF8(b)
+	}
+	switch policy {
+	case InvalidUTF8Reject:
+		if !utf8.Valid(b) {
+			return "", ErrInvalidUTF8
+		}
+	case InvalidUTF8Replace:
+		if !utf8.Valid(b) {
+			return strings.ToValidUTF8(string(b), "\uFFFD"), nil
+		}
+	}
+	return string(b), nil
+}
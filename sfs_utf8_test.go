@@ -0,0 +1,141 @@
+package sfs
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestModifiedUTF8(t *testing.T) {
+	s := "a\x00😀é"
+	// Java DataOutputStream.writeUTF 的输出
+	java := []byte{'a', 0xC0, 0x80, 0xED, 0xA0, 0xBD, 0xED, 0xB8, 0x80, 0xC3, 0xA9}
+
+	obj := SFSObject{"k\x00": s, "arr": []string{s}}
+	data, err := Pack(SFSObject{"s": s}, PackOptions{Strings: ModifiedUTF8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasSuffix(data, java) {
+		t.Fatalf("unexpected encoding: %x", data)
+	}
+
+	data, err = Pack(obj, PackOptions{Strings: ModifiedUTF8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := Unpack(data, UnpackOptions{Strings: ModifiedUTF8, InvalidUTF8: InvalidUTF8Reject})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := v.(SFSObject)
+	if got["k\x00"] != s || got["arr"].([]string)[0] != s {
+		t.Fatalf("round trip mismatch: %q", got)
+	}
+
+	// 按标准 UTF-8 读取时，代理项是不合法的 UTF-8
+	if _, err := Unpack(data, UnpackOptions{InvalidUTF8: InvalidUTF8Reject}); !errors.Is(err, ErrInvalidUTF8) {
+		t.Fatalf("expected ErrInvalidUTF8, got %v", err)
+	}
+}
+
+func TestInvalidUTF8Policy(t *testing.T) {
+	data, err := Pack(SFSObject{"s": "\xffa"}, PackOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		policy InvalidUTF8Policy
+		want   string
+	}{
+		{InvalidUTF8Pass, "\xffa"},
+		{InvalidUTF8Replace, "\uFFFDa"},
+	}
+	for _, c := range cases {
+		v, err := Unpack(data, UnpackOptions{InvalidUTF8: c.policy})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := v.(SFSObject)["s"]; got != c.want {
+			t.Errorf("policy %d: got %q, want %q", c.policy, got, c.want)
+		}
+	}
+
+	_, err = Unpack(data, UnpackOptions{InvalidUTF8: InvalidUTF8Reject})
+	var de *DecodeError
+	if !errors.As(err, &de) || !errors.Is(err, ErrInvalidUTF8) || de.Path != "s" {
+		t.Fatalf("expected ErrInvalidUTF8 at s, got %v", err)
+	}
+}
+
+// Packer、Unpacker、Encoder、Decoder、View 和 sfsbin 字段都可以使用 modified UTF-8
+func TestStringOptions(t *testing.T) {
+	s := "a\x00😀é"
+	obj := SFSObject{"k\x00": s}
+	want, err := Pack(obj, PackOptions{Strings: ModifiedUTF8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := UnpackOptions{Strings: ModifiedUTF8, InvalidUTF8: InvalidUTF8Reject}
+
+	p := NewPacker()
+	p.SetStrings(ModifiedUTF8)
+	data, err := p.Pack(obj, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, want) {
+		t.Fatalf("Packer = %x, want %x", data, want)
+	}
+
+	enc := NewEncoder()
+	enc.SetStrings(ModifiedUTF8)
+	enc.BeginObject()
+	enc.Key("k\x00")
+	enc.WriteUTF(s)
+	enc.End()
+	if data, err = enc.Bytes(false); err != nil || !bytes.Equal(data, want) {
+		t.Fatalf("Encoder = %x, %v; want %x", data, err, want)
+	}
+
+	u := NewUnpacker(want)
+	u.SetOptions(opts)
+	if v, err := u.Unpack(); err != nil || v.(SFSObject)["k\x00"] != s {
+		t.Fatalf("Unpacker = %q, %v", v, err)
+	}
+
+	dec := NewDecoder(bytes.NewReader(want))
+	dec.SetOptions(opts)
+	if v := buildFromTokens(t, dec); v.(SFSObject)["k\x00"] != s {
+		t.Fatalf("Decoder = %q", v)
+	}
+
+	view, err := NewView(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	view = view.WithOptions(opts)
+	if got := view.Get("k\x00").String(); got != s {
+		t.Fatalf("View = %q, want %q", got, s)
+	}
+	if v, err := view.Value(); err != nil || v.(SFSObject)["k\x00"] != s {
+		t.Fatalf("View.Value = %q, %v", v, err)
+	}
+
+	type blobHolder struct {
+		Blob map[string]string `sfs:"blob,sfsbin,mutf8"`
+	}
+	in := blobHolder{Blob: map[string]string{"s": s}}
+	m, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(m["blob"].([]byte), []byte{0xC0, 0x80, 0xED}) {
+		t.Fatalf("sfsbin not encoded as modified UTF-8: %x", m["blob"])
+	}
+	var out blobHolder
+	if err := (UnmarshalOptions{InvalidUTF8: InvalidUTF8Reject}).Unmarshal(m, &out); err != nil || out.Blob["s"] != s {
+		t.Fatalf("sfsbin round trip = %q, %v", out.Blob, err)
+	}
+}
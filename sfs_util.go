@@ -3,6 +3,8 @@ package sfs
 import (
 	"fmt"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -11,6 +13,7 @@ func parseTag(field reflect.StructField) (fieldInfo, error) {
 		name:     field.Name,
 		dataType: NULL,
 		optional: false,
+		pos:      -1,
 	}
 
 	tag := field.Tag.Get(tagName)
@@ -18,17 +21,146 @@ func parseTag(field reflect.StructField) (fieldInfo, error) {
 		return info, nil
 	}
 
+	// decimal=N 的 as= 默认为 LONG，round= 默认为 half_up
+	decimal := decimalInfo{as: LONG, mode: RoundHalfUp}
+	hasDecimal := false
+	decimalOpt := "" // 出现过的 as= / round= 选项
+	bitGroupOpt, bitPos := "", 0
+
 	parts := strings.Split(tag, ",")
 	if len(parts) > 0 && parts[0] != "" {
 		info.name = parts[0]
 	}
 
-	for _, part := range parts[1:] {
+	for i, part := range parts[1:] {
+		if opt, ok := parseConstraint(part); ok {
+			if opt.name == "pattern" {
+				// 正则表达式可以有逗号，pattern= 之后的内容都属于它；
+				// 其中能解析为选项的部分说明 pattern= 没有放在最后
+				rest := parts[i+2:]
+				for _, p := range rest {
+					if isTagOption(p) {
+						return info, fmt.Errorf("pattern= must be the last option, found %q after it", p)
+					}
+				}
+				opt.arg = strings.Join(append([]string{opt.arg}, rest...), ",")
+				info.constraints = append(info.constraints, opt)
+				break
+			}
+			info.constraints = append(info.constraints, opt)
+			continue
+		}
+
 		if part == "optional" {
 			info.optional = true
 			continue
 		}
 
+		if part == "bits" {
+			if info.bits == nil {
+				info.bits = &bitsInfo{bit: -1}
+			}
+			continue
+		}
+
+		if strings.HasPrefix(part, "shape=") {
+			shape, err := parseShape(strings.TrimPrefix(part, "shape="))
+			if err != nil {
+				return info, err
+			}
+			if info.bits == nil {
+				info.bits = &bitsInfo{bit: -1}
+			}
+			info.bits.shape = shape
+			continue
+		}
+
+		if strings.HasPrefix(part, "bit=") {
+			bit, err := strconv.Atoi(strings.TrimPrefix(part, "bit="))
+			if err != nil || bit < 0 || bit > 63 {
+				return info, fmt.Errorf("invalid bit position in %q", part)
+			}
+			bitGroupOpt = part
+			bitPos = bit
+			continue
+		}
+
+		if part == "json" || part == "sfsbin" {
+			if info.payload != payloadNone {
+				return info, fmt.Errorf("json and sfsbin cannot be used together")
+			}
+			info.payload = payloadJSON
+			if part == "sfsbin" {
+				info.payload = payloadSFSBin
+			}
+			continue
+		}
+
+		if part == "mutf8" {
+			info.payloadStrings = ModifiedUTF8
+			continue
+		}
+
+		if strings.HasPrefix(part, "alias=") {
+			for _, alias := range strings.Split(strings.TrimPrefix(part, "alias="), "|") {
+				if alias == "" {
+					return info, fmt.Errorf("empty alias in %q", part)
+				}
+				info.aliases = append(info.aliases, alias)
+			}
+			continue
+		}
+
+		if strings.HasPrefix(part, "index=") {
+			pos, err := strconv.Atoi(strings.TrimPrefix(part, "index="))
+			if err != nil || pos < 0 || pos >= maxCount {
+				return info, fmt.Errorf("invalid index in %q", part)
+			}
+			info.pos = pos
+			continue
+		}
+
+		if strings.HasPrefix(part, "discriminator=") {
+			info.discriminator = strings.TrimPrefix(part, "discriminator=")
+			if info.discriminator == "" {
+				return info, fmt.Errorf("empty key in %q", part)
+			}
+			continue
+		}
+
+		if strings.HasPrefix(part, "decimal=") {
+			scale, err := strconv.Atoi(strings.TrimPrefix(part, "decimal="))
+			if err != nil || scale < 0 || scale > maxDecimalScale {
+				return info, fmt.Errorf("invalid decimal places in %q", part)
+			}
+			decimal.scale = scale
+			hasDecimal = true
+			continue
+		}
+
+		if strings.HasPrefix(part, "as=") {
+			as, err := parseDataType(strings.TrimPrefix(part, "as="))
+			if err != nil {
+				return info, err
+			}
+			if as != LONG && as != UTF_STRING {
+				return info, fmt.Errorf("as=%s is not supported, use LONG or UTF_STRING", as)
+			}
+			decimal.as = as
+			decimalOpt = part
+			continue
+		}
+
+		if strings.HasPrefix(part, "round=") {
+			mode, err := parseRoundingMode(strings.TrimPrefix(part, "round="))
+			if err != nil {
+				return info, err
+			}
+			decimal.mode = mode
+			decimalOpt = part
+			continue
+		}
+
 		if strings.HasPrefix(part, "type=") {
 			typeStr := strings.TrimPrefix(part, "type=")
 			dtype, err := parseDataType(typeStr)
@@ -36,12 +168,54 @@ func parseTag(field reflect.StructField) (fieldInfo, error) {
 				return info, err
 			}
 			info.dataType = dtype
+			continue
 		}
+
+		// Marshal/Unmarshal 忽略未知选项以兼容已有的 tag，只由 Precompile 报告
+		info.unknown = append(info.unknown, part)
 	}
 
+	if bitGroupOpt != "" {
+		if info.bits != nil {
+			return info, fmt.Errorf("%q cannot be combined with bits or shape=", bitGroupOpt)
+		}
+		info.bits = &bitsInfo{bit: bitPos}
+	}
+	if info.payloadStrings == ModifiedUTF8 && info.payload != payloadSFSBin {
+		return info, fmt.Errorf("mutf8 requires sfsbin")
+	}
+	if info.bits != nil && (hasDecimal || info.payload != payloadNone) {
+		return info, fmt.Errorf("bit options cannot be combined with decimal=, json or sfsbin")
+	}
+
+	if hasDecimal {
+		info.decimal = &decimal
+	} else if decimalOpt != "" {
+		return info, fmt.Errorf("%q requires decimal=", decimalOpt)
+	}
 	return info, nil
 }
 
+// isTagOption 报告 part 是否是 parseTag 支持的选项
+func isTagOption(part string) bool {
+	switch part {
+	case "optional", "bits", "json", "sfsbin", "mutf8":
+		return true
+	}
+	if _, ok := parseConstraint(part); ok {
+		return true
+	}
+	name, _, found := strings.Cut(part, "=")
+	if !found {
+		return false
+	}
+	switch name {
+	case "shape", "bit", "alias", "index", "discriminator", "decimal", "as", "round", "type":
+		return true
+	}
+	return false
+}
+
 func parseDataType(s string) (DataType, error) {
 	switch strings.ToUpper(s) {
 	case "NULL":
@@ -88,3 +262,12 @@ func parseDataType(s string) (DataType, error) {
 		return NULL, fmt.Errorf("unknown data type: %s", s)
 	}
 }
+
+func sortedKeys(obj SFSObject) []string {
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,180 @@
+package sfs
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// variantTypes 保存 名称 -> reflect.Type，variantNames 保存 reflect.Type -> 名称；
+// variantMu 保证注册时的检查和写入是原子的
+var (
+	variantTypes, variantNames sync.Map
+	variantMu                  sync.Mutex
+)
+
+// RegisterVariant 将结构体类型 v 注册为名称 name，用于带 discriminator= 选项的接口字段：
+//
+//	type Results struct {
+//		Results []SpecialResult `sfs:"results,discriminator=type"`
+//	}
+//	sfs.RegisterVariant("freeSpin", FreeSpinResult{})
+//
+// Marshal 将接口中的值编码为 SFSObject 并写入 "type": "freeSpin"，
+// Unmarshal 按对象中 "type" 的值创建 FreeSpinResult 再解码。
+// v 是指针（如 &FreeSpinResult{}）时解码得到指针。应在程序启动时注册。
+// name 或类型已经注册为其他组合时返回错误，重复注册相同的组合不起作用。
+func RegisterVariant(name string, v interface{}) error {
+	t := reflect.TypeOf(v)
+	if t == nil || t.Kind() != reflect.Struct && (t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct) {
+		return fmt.Errorf("variant %q must be a struct or struct pointer, got %v", name, t)
+	}
+
+	variantMu.Lock()
+	defer variantMu.Unlock()
+	prevType, typeOK := variantTypes.Load(name)
+	prevName, nameOK := variantNames.Load(t)
+	if typeOK && nameOK && prevType == t && prevName == name {
+		return nil
+	}
+	if typeOK {
+		return fmt.Errorf("variant %q already registered for %v", name, prevType)
+	}
+	if nameOK {
+		return fmt.Errorf("variant %v already registered as %q", t, prevName)
+	}
+	variantTypes.Store(name, t)
+	variantNames.Store(t, name)
+	return nil
+}
+
+// checkVariantType 检查 discriminator 字段的 Go 类型：接口，或元素为接口的切片/数组
+func checkVariantType(info fieldInfo, t reflect.Type) error {
+	if info.dataType != NULL && info.dataType != SFS_OBJECT && info.dataType != SFS_ARRAY {
+		return fmt.Errorf("discriminator cannot be stored as %s", info.dataType)
+	}
+	if info.payload != payloadNone || info.decimal != nil || info.bits != nil {
+		return fmt.Errorf("discriminator cannot be combined with other encoding options")
+	}
+	if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Interface {
+		return fmt.Errorf("discriminator cannot be used with %s", t)
+	}
+	return nil
+}
+
+// encodeVariant 编码接口字段（或接口切片），每个对象写入 discriminator key，
+// nil 元素编码为 NULL
+func encodeVariant(val reflect.Value, key string) (interface{}, error) {
+	if val.Kind() == reflect.Interface {
+		return encodeVariantValue(val, key)
+	}
+	if val.Kind() == reflect.Slice && val.IsNil() {
+		return nil, nil
+	}
+	arr := make(SFSArray, val.Len())
+	for i := range arr {
+		obj, err := encodeVariantValue(val.Index(i), key)
+		if err != nil {
+			return nil, prefixPath(err, indexSeg(i), val.Index(i).Type(), SFS_OBJECT)
+		}
+		arr[i] = obj
+	}
+	return arr, nil
+}
+
+func encodeVariantValue(val reflect.Value, key string) (interface{}, error) {
+	if val.IsNil() {
+		return nil, nil
+	}
+	elem := val.Elem()
+	name, ok := variantNames.Load(elem.Type())
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is not registered", ErrUnknownVariant, elem.Type())
+	}
+
+	obj, err := Marshal(elem.Interface())
+	if err != nil {
+		return nil, err
+	}
+	if prev, ok := obj[key]; ok && prev != name {
+		return nil, fmt.Errorf("field %q of %s conflicts with discriminator %q", key, elem.Type(), name)
+	}
+	obj[key] = name
+	return obj, nil
+}
+
+// decodeVariant 按对象中 discriminator key 的值选择注册的类型解码
+func (d *decoder) decodeVariant(field reflect.Value, sfsValue interface{}, key string) error {
+	if sfsValue == nil {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+	if field.Kind() == reflect.Interface {
+		return d.decodeVariantValue(field, sfsValue, key)
+	}
+
+	arr, ok := sfsValue.(SFSArray)
+	if !ok {
+		return fmt.Errorf("cannot convert %T to %s", sfsValue, field.Type())
+	}
+	slice, err := makeSeq(field.Type(), len(arr))
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for i, elem := range arr {
+		if err := d.decodeVariantValue(slice.Index(i), elem, key); err != nil {
+			err = prefixPath(err, indexSeg(i), field.Type().Elem(), wireTypeOf(elem))
+			if !d.opts.ReportAll {
+				return err
+			}
+			errs = appendError(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return joinErrors(errs)
+	}
+	field.Set(slice)
+	return nil
+}
+
+func (d *decoder) decodeVariantValue(field reflect.Value, sfsValue interface{}, key string) error {
+	if sfsValue == nil {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+	obj, ok := sfsValue.(SFSObject)
+	if !ok {
+		return fmt.Errorf("cannot convert %T to %s", sfsValue, field.Type())
+	}
+	name, ok := obj[key].(string)
+	if !ok {
+		return fmt.Errorf("missing discriminator %q", key)
+	}
+	t, ok := variantTypes.Load(name)
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownVariant, name)
+	}
+	typ := t.(reflect.Type)
+
+	base := typ
+	if base.Kind() == reflect.Ptr {
+		base = base.Elem()
+	}
+	v := reflect.New(base)
+	// 结构体可以有自己的 discriminator 字段，没有时该 key 也不算未知字段
+	if err := d.decodeStruct(obj, v.Elem(), key); err != nil {
+		return err
+	}
+	if typ.Kind() != reflect.Ptr {
+		v = v.Elem()
+	}
+	if !v.Type().AssignableTo(field.Type()) {
+		return fmt.Errorf("variant %q (%s) does not implement %s", name, v.Type(), field.Type())
+	}
+	field.Set(v)
+	return nil
+}
@@ -0,0 +1,159 @@
+package sfs
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type specialResult interface {
+	feature() string
+}
+
+type freeSpinResult struct {
+	Count      int32   `sfs:"count"`
+	Multiplier float64 `sfs:"multiplier"`
+}
+
+func (freeSpinResult) feature() string { return "freeSpin" }
+
+type bonusResult struct {
+	Picks []int32 `sfs:"picks"`
+}
+
+func (*bonusResult) feature() string { return "bonus" }
+
+// wheelResult 自己声明了 discriminator 字段
+type wheelResult struct {
+	Kind   string `sfs:"specialHitInfo"`
+	Amount int64  `sfs:"amount"`
+}
+
+func (wheelResult) feature() string { return "wheel" }
+
+type specialHitInfo struct {
+	Main    specialResult   `sfs:"main,discriminator=specialHitInfo"`
+	Results []specialResult `sfs:"results,discriminator=specialHitInfo"`
+	Extra   specialResult   `sfs:"extra,discriminator=specialHitInfo,optional"`
+}
+
+func TestVariant(t *testing.T) {
+	for name, v := range map[string]interface{}{
+		"freeSpin": freeSpinResult{},
+		"bonus":    &bonusResult{},
+		"wheel":    wheelResult{},
+	} {
+		if err := RegisterVariant(name, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	in := specialHitInfo{
+		Main:    freeSpinResult{Count: 10, Multiplier: 2},
+		Results: []specialResult{&bonusResult{Picks: []int32{1, 3}}, freeSpinResult{Count: 5, Multiplier: 1}},
+	}
+	obj, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := SFSObject{
+		"main": SFSObject{"specialHitInfo": "freeSpin", "count": int32(10), "multiplier": float64(2)},
+		"results": SFSArray{
+			SFSObject{"specialHitInfo": "bonus", "picks": []int32{1, 3}},
+			SFSObject{"specialHitInfo": "freeSpin", "count": int32(5), "multiplier": float64(1)},
+		},
+	}
+	if !reflect.DeepEqual(obj, want) {
+		t.Fatalf("Marshal = %v, want %v", obj, want)
+	}
+
+	var out specialHitInfo
+	if err := (UnmarshalOptions{DisallowUnknownFields: true}).Unmarshal(obj, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Fatalf("Unmarshal = %+v, want %+v", out, in)
+	}
+
+	// DisallowUnknownFields 时，自带 discriminator 字段的结构体也能拿到它的值；
+	// 其他未知 key 仍然报错
+	in = specialHitInfo{Main: wheelResult{Kind: "wheel", Amount: 100}, Results: []specialResult{}}
+	obj, err = Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = specialHitInfo{}
+	if err := (UnmarshalOptions{DisallowUnknownFields: true}).Unmarshal(obj, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Fatalf("Unmarshal = %+v, want %+v", out, in)
+	}
+	obj["main"].(SFSObject)["extra"] = true
+	err = UnmarshalOptions{DisallowUnknownFields: true}.Unmarshal(obj, &out)
+	var fe *FieldError
+	if !errors.Is(err, ErrUnknownField) || !errors.As(err, &fe) || fe.Path != "main.extra" {
+		t.Fatalf("expected unknown field main.extra, got %v", err)
+	}
+
+	// nil 元素编码为 NULL，经过 Pack/Unpack 后还原为 nil
+	in = specialHitInfo{Main: freeSpinResult{Count: 1}, Results: []specialResult{nil, &bonusResult{}}}
+	obj, err = Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := Pack(obj, PackOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	unpacked, err := Unpack(data, UnpackOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = specialHitInfo{}
+	if err := Unmarshal(unpacked.(SFSObject), &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Fatalf("Unmarshal = %+v, want %+v", out, in)
+	}
+
+	bad := SFSObject{
+		"main":    SFSObject{"specialHitInfo": "jackpot"},
+		"results": SFSArray{SFSObject{"count": int32(1)}},
+	}
+	err = UnmarshalOptions{ReportAll: true}.Unmarshal(bad, &out)
+	var me *MultiError
+	if !errors.As(err, &me) || len(me.Errors) != 2 || !errors.Is(err, ErrUnknownVariant) {
+		t.Fatalf("expected unknown variant and missing discriminator, got %v", err)
+	}
+	if path := me.Errors[1].(*FieldError).Path; path != "results[0]" {
+		t.Errorf("path = %q", path)
+	}
+
+	type unregistered struct{ specialResult }
+	if _, err := Marshal(specialHitInfo{Main: unregistered{}}); !errors.Is(err, ErrUnknownVariant) {
+		t.Fatalf("expected ErrUnknownVariant, got %v", err)
+	}
+
+	type badVariant struct {
+		A freeSpinResult `sfs:"a,discriminator=type"`
+		B []int32        `sfs:"b,discriminator=type"`
+	}
+	if err := Precompile(badVariant{}); err == nil {
+		t.Fatal("expected error for non-interface discriminator fields")
+	}
+
+	if err := RegisterVariant("freeSpin", wheelResult{}); err == nil {
+		t.Fatal("expected error for a duplicate variant name")
+	}
+	if err := RegisterVariant("spin", freeSpinResult{}); err == nil {
+		t.Fatal("expected error for a duplicate variant type")
+	}
+	if err := RegisterVariant("int", 1); err == nil {
+		t.Fatal("expected error for a non-struct variant")
+	}
+	if typ, _ := variantTypes.Load("freeSpin"); typ != reflect.TypeOf(freeSpinResult{}) {
+		t.Fatalf("freeSpin was overwritten by %v", typ)
+	}
+}
@@ -0,0 +1,441 @@
+package sfs
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// View 是对已打包 SFS 数据的只读惰性视图。
+// 它只在访问时按需跳过和定位值，不构造中间的 map；
+// StringBytes 和 Bytes 返回的切片直接引用原始缓冲区，调用方不能修改。
+//
+// 访问不存在的 key 或类型不匹配时返回零值 View / 零值，不会 panic，
+// 因此可以链式调用：view.Get("p").Get("c").String()。
+type View struct {
+	buf  []byte // 整个对象数据
+	off  int    // 当前值（类型字节）在 buf 中的偏移
+	ok   bool
+	err  error
+	opts UnpackOptions
+}
+
+// NewView 解析数据包头并返回根对象的视图。
+// 未压缩的数据包不会复制；压缩的数据包会先解压到新的缓冲区。
+func NewView(packet []byte) (View, error) {
+	data, err := splitPacket(packet)
+	if err != nil {
+		return View{}, err
+	}
+	return ViewOf(data)
+}
+
+// ViewOf 返回不带包头的对象数据（以类型字节开头）的视图。
+// 数据只在访问时校验，截断或损坏的数据通过 Err 报告。
+func ViewOf(data []byte) (View, error) {
+	if len(data) == 0 {
+		err := &DecodeError{Offset: 0, Type: NULL, Err: ErrTruncated}
+		return View{err: err}, err
+	}
+	return View{buf: data, ok: true}, nil
+}
+
+// WithOptions 返回使用 opts 中 Strings 和 InvalidUTF8 选项的视图，
+// 由它得到的子视图沿用这些选项；MaxSize 不起作用
+func (v View) WithOptions(opts UnpackOptions) View {
+	v.opts = opts
+	return v
+}
+
+// Exists 报告该值是否存在
+func (v View) Exists() bool {
+	return v.ok
+}
+
+// Err 返回定位该值时遇到的数据错误（截断、未知类型等）
+func (v View) Err() error {
+	return v.err
+}
+
+// Type 返回值的数据类型，不存在时返回 NULL
+func (v View) Type() DataType {
+	if !v.ok {
+		return NULL
+	}
+	return DataType(v.buf[v.off])
+}
+
+// Offset 返回值在对象数据中的字节偏移
+func (v View) Offset() int {
+	return v.off
+}
+
+// Raw 返回该值完整的编码字节（含类型字节），引用原始缓冲区
+func (v View) Raw() []byte {
+	if !v.ok {
+		return nil
+	}
+	n, err := v.size()
+	if err != nil {
+		return nil
+	}
+	return v.buf[v.off : v.off+n]
+}
+
+// Len 返回 SFS_OBJECT 的 key 数量或数组的元素数量，其他类型返回 0
+func (v View) Len() int {
+	switch v.Type() {
+	case SFS_OBJECT, SFS_ARRAY, BOOL_ARRAY, SHORT_ARRAY, INT_ARRAY, LONG_ARRAY,
+		FLOAT_ARRAY, DOUBLE_ARRAY, UTF_STRING_ARRAY:
+		if n, ok := v.uint16At(v.off + 1); ok {
+			return int(n)
+		}
+	case BYTE_ARRAY:
+		if n, ok := v.uint32At(v.off + 1); ok {
+			return int(n)
+		}
+	}
+	return 0
+}
+
+// Get 返回 SFS_OBJECT 中 key 对应的值
+func (v View) Get(key string) View {
+	if v.Type() != SFS_OBJECT {
+		return View{err: v.err}
+	}
+
+	if v.opts.Strings == ModifiedUTF8 {
+		key = string(appendModifiedUTF8(nil, key))
+	}
+	var found View
+	err := v.Range(func(k []byte, val View) bool {
+		if string(k) == key {
+			found = val
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return View{err: err}
+	}
+	return found
+}
+
+// Index 返回 SFS_ARRAY 中下标 i 的元素
+func (v View) Index(i int) View {
+	if v.Type() != SFS_ARRAY || i < 0 {
+		return View{err: v.err}
+	}
+	count := v.Len()
+	if i >= count {
+		return View{}
+	}
+
+	pos := v.off + 3
+	for j := 0; j < i; j++ {
+		n, err := v.at(pos).size()
+		if err != nil {
+			return View{err: err}
+		}
+		pos += n
+	}
+	return v.at(pos)
+}
+
+// Range 依次遍历 SFS_OBJECT 的 key/value 或 SFS_ARRAY 的元素（key 为 nil），
+// fn 返回 false 时停止。key 引用原始缓冲区。
+// 只在跳过一个值时才计算它的长度，遇到损坏的数据时返回 DecodeError。
+func (v View) Range(fn func(key []byte, val View) bool) error {
+	if v.err != nil {
+		return v.err
+	}
+	typ := v.Type()
+	if typ != SFS_OBJECT && typ != SFS_ARRAY {
+		return nil
+	}
+
+	count, ok := v.uint16At(v.off + 1)
+	if !ok {
+		return &DecodeError{Offset: v.off, Type: typ, Err: ErrTruncated}
+	}
+	pos := v.off + 3
+	for i := 0; i < int(count); i++ {
+		var key []byte
+		if typ == SFS_OBJECT {
+			keyLen, ok := v.uint16At(pos)
+			if !ok || pos+2+int(keyLen) > len(v.buf) {
+				return &DecodeError{Offset: pos, Type: typ, Err: ErrTruncated}
+			}
+			key = v.buf[pos+2 : pos+2+int(keyLen)]
+			pos += 2 + int(keyLen)
+		}
+
+		val := v.at(pos)
+		if val.err != nil {
+			return val.err
+		}
+		if !fn(key, val) {
+			return nil
+		}
+		n, err := val.size()
+		if err != nil {
+			return err
+		}
+		pos += n
+	}
+	return nil
+}
+
+// Bool 返回 BOOL 值
+func (v View) Bool() bool {
+	b := v.payload(BOOL, 1)
+	return b != nil && b[0] != 0
+}
+
+// Int 返回 BYTE/SHORT/INT/LONG 的整数值，其他类型返回 0
+func (v View) Int() int64 {
+	switch v.Type() {
+	case BYTE:
+		if b := v.payload(BYTE, 1); b != nil {
+			return int64(b[0])
+		}
+	case SHORT:
+		if b := v.payload(SHORT, 2); b != nil {
+			return int64(int16(binary.BigEndian.Uint16(b)))
+		}
+	case INT:
+		if b := v.payload(INT, 4); b != nil {
+			return int64(int32(binary.BigEndian.Uint32(b)))
+		}
+	case LONG:
+		if b := v.payload(LONG, 8); b != nil {
+			return int64(binary.BigEndian.Uint64(b))
+		}
+	}
+	return 0
+}
+
+// Float 返回 FLOAT/DOUBLE 的值，其他类型返回 0
+func (v View) Float() float64 {
+	switch v.Type() {
+	case FLOAT:
+		if b := v.payload(FLOAT, 4); b != nil {
+			return float64(math.Float32frombits(binary.BigEndian.Uint32(b)))
+		}
+	case DOUBLE:
+		if b := v.payload(DOUBLE, 8); b != nil {
+			return math.Float64frombits(binary.BigEndian.Uint64(b))
+		}
+	}
+	return 0
+}
+
+// StringBytes 返回 UTF_STRING/TEXT 的原始字节，引用原始缓冲区
+func (v View) StringBytes() []byte {
+	switch v.Type() {
+	case UTF_STRING:
+		if n, ok := v.uint16At(v.off + 1); ok && v.off+3+int(n) <= len(v.buf) {
+			return v.buf[v.off+3 : v.off+3+int(n)]
+		}
+	case TEXT:
+		if n, ok := v.uint32At(v.off + 1); ok && v.off+5+int(n) <= len(v.buf) {
+			return v.buf[v.off+5 : v.off+5+int(n)]
+		}
+	}
+	return nil
+}
+
+// String 按 Strings 和 InvalidUTF8 选项返回 UTF_STRING/TEXT 的值，
+// 其他类型或字符串被 InvalidUTF8Reject 拒绝时返回空字符串
+func (v View) String() string {
+	enc := v.opts.Strings
+	if v.Type() == TEXT {
+		enc = StandardUTF8
+	}
+	s, err := decodeString(v.StringBytes(), enc, v.opts.InvalidUTF8)
+	if err != nil {
+		return ""
+	}
+	return s
+}
+
+// Bytes 返回 BYTE_ARRAY 的内容，引用原始缓冲区
+func (v View) Bytes() []byte {
+	if v.Type() != BYTE_ARRAY {
+		return nil
+	}
+	if n, ok := v.uint32At(v.off + 1); ok && v.off+5+int(n) <= len(v.buf) {
+		return v.buf[v.off+5 : v.off+5+int(n)]
+	}
+	return nil
+}
+
+// Value 完整解码该值（与 Unpacker 的结果相同），
+// 错误的 DecodeError.Offset 与 Unpacker 一样相对于整个对象数据
+func (v View) Value() (interface{}, error) {
+	if v.err != nil {
+		return nil, v.err
+	}
+	if !v.ok {
+		return nil, nil
+	}
+	n, err := v.size()
+	if err != nil {
+		return nil, err
+	}
+	u := &Unpacker{data: v.buf[v.off : v.off+n], opts: v.opts}
+	value, err := u.decodeValue()
+	if de, ok := err.(*DecodeError); ok {
+		de.Offset += v.off
+	}
+	return value, err
+}
+
+// payload 返回定长标量类型的数据部分，类型不符或数据不完整时返回 nil
+func (v View) payload(typ DataType, n int) []byte {
+	if v.Type() != typ || v.off+1+n > len(v.buf) {
+		return nil
+	}
+	return v.buf[v.off+1 : v.off+1+n]
+}
+
+func (v View) at(off int) View {
+	if off >= len(v.buf) {
+		return View{err: &DecodeError{Offset: off, Type: NULL, Err: ErrTruncated}}
+	}
+	return View{buf: v.buf, off: off, ok: true, opts: v.opts}
+}
+
+func (v View) uint16At(off int) (uint16, bool) {
+	if off+2 > len(v.buf) {
+		return 0, false
+	}
+	return binary.BigEndian.Uint16(v.buf[off:]), true
+}
+
+func (v View) uint32At(off int) (uint32, bool) {
+	if off+4 > len(v.buf) {
+		return 0, false
+	}
+	return binary.BigEndian.Uint32(v.buf[off:]), true
+}
+
+// size 返回该值编码后的总字节数（含类型字节），并检查数据是否完整
+func (v View) size() (int, error) {
+	if v.err != nil {
+		return 0, v.err
+	}
+	if !v.ok {
+		return 0, nil
+	}
+
+	typ := DataType(v.buf[v.off])
+	n, err := v.sizeOf(typ)
+	if err != nil {
+		if _, ok := err.(*DecodeError); ok {
+			return 0, err
+		}
+		return 0, &DecodeError{Offset: v.off, Type: typ, Err: err}
+	}
+	if v.off+n > len(v.buf) {
+		return 0, &DecodeError{Offset: v.off, Type: typ, Err: ErrTruncated}
+	}
+	return n, nil
+}
+
+func (v View) sizeOf(typ DataType) (int, error) {
+	pos := v.off + 1
+	switch typ {
+	case NULL:
+		return 1, nil
+	case BOOL, BYTE:
+		return 2, nil
+	case SHORT:
+		return 3, nil
+	case INT, FLOAT:
+		return 5, nil
+	case LONG, DOUBLE:
+		return 9, nil
+	case UTF_STRING:
+		n, ok := v.uint16At(pos)
+		if !ok {
+			return 0, ErrTruncated
+		}
+		return 3 + int(n), nil
+	case TEXT:
+		n, ok := v.uint32At(pos)
+		if !ok {
+			return 0, ErrTruncated
+		}
+		return 5 + int(n), nil
+	case BYTE_ARRAY:
+		n, ok := v.uint32At(pos)
+		if !ok {
+			return 0, ErrTruncated
+		}
+		return 5 + int(n), nil
+	case BOOL_ARRAY, SHORT_ARRAY, INT_ARRAY, LONG_ARRAY, FLOAT_ARRAY, DOUBLE_ARRAY:
+		n, ok := v.uint16At(pos)
+		if !ok {
+			return 0, ErrTruncated
+		}
+		return 3 + int(n)*elemSize(typ), nil
+	case UTF_STRING_ARRAY:
+		count, ok := v.uint16At(pos)
+		if !ok {
+			return 0, ErrTruncated
+		}
+		pos += 2
+		for i := 0; i < int(count); i++ {
+			n, ok := v.uint16At(pos)
+			if !ok {
+				return 0, ErrTruncated
+			}
+			pos += 2 + int(n)
+		}
+		return pos - v.off, nil
+	case SFS_OBJECT, SFS_ARRAY:
+		count, ok := v.uint16At(pos)
+		if !ok {
+			return 0, ErrTruncated
+		}
+		pos += 2
+		for i := 0; i < int(count); i++ {
+			if typ == SFS_OBJECT {
+				keyLen, ok := v.uint16At(pos)
+				if !ok {
+					return 0, ErrTruncated
+				}
+				if keyLen > maxKeyLength {
+					return 0, fmt.Errorf("%w: key length %d", ErrLimitExceeded, keyLen)
+				}
+				pos += 2 + int(keyLen)
+			}
+			elem := v.at(pos)
+			n, err := elem.size()
+			if err != nil {
+				return 0, err
+			}
+			pos += n
+		}
+		return pos - v.off, nil
+	default:
+		return 0, ErrUnknownType
+	}
+}
+
+// elemSize 返回定长数组元素的字节数
+func elemSize(typ DataType) int {
+	switch typ {
+	case BOOL_ARRAY, BYTE_ARRAY:
+		return 1
+	case SHORT_ARRAY:
+		return 2
+	case INT_ARRAY, FLOAT_ARRAY:
+		return 4
+	case LONG_ARRAY, DOUBLE_ARRAY:
+		return 8
+	default:
+		return 0
+	}
+}
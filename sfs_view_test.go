@@ -0,0 +1,128 @@
+package sfs
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestView(t *testing.T) {
+	entity := []byte(`{"balance":80000000310.400}`)
+	obj := SFSObject{
+		"a": byte(13),
+		"c": int16(1),
+		"p": SFSObject{
+			"c": "h5.spinResponse",
+			"p": SFSObject{
+				"code":   "spinResponse",
+				"entity": entity,
+				"flags":  []bool{true, false, true},
+				"seq":    int64(7499736444769),
+			},
+			"list": SFSArray{int32(1), "two", SFSObject{"x": 2.5}},
+		},
+	}
+	data, err := NewPacker().Pack(obj, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	view, err := NewView(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := view.Get("p").Get("c").String(); got != "h5.spinResponse" {
+		t.Fatalf("p.c = %q", got)
+	}
+	if got := view.Get("a").Int(); got != 13 {
+		t.Fatalf("a = %d", got)
+	}
+	if got := view.Get("p").Get("p").Get("seq").Int(); got != 7499736444769 {
+		t.Fatalf("p.p.seq = %d", got)
+	}
+	if got := view.Get("p").Get("list").Index(2).Get("x").Float(); got != 2.5 {
+		t.Fatalf("p.list[2].x = %v", got)
+	}
+
+	// 字节数组直接引用原始缓冲区
+	b := view.Get("p").Get("p").Get("entity").Bytes()
+	if !bytes.Equal(b, entity) {
+		t.Fatalf("entity = %q", b)
+	}
+	if i := bytes.Index(data, entity); i < 0 || &data[i] != &b[0] {
+		t.Fatal("Bytes should point into the packed buffer")
+	}
+
+	if view.Get("missing").Get("c").Exists() {
+		t.Fatal("missing key should not exist")
+	}
+
+	sub, err := view.Get("p").Get("p").Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(sub, obj["p"].(SFSObject)["p"]) {
+		t.Fatalf("Value mismatch: %v", sub)
+	}
+
+	truncatedView, _ := NewView(append(data[:0:0], data...))
+	truncatedView.buf = truncatedView.buf[:len(truncatedView.buf)-4]
+	if v := truncatedView.Get("zzz"); !errors.Is(v.Err(), ErrTruncated) {
+		t.Fatalf("expected ErrTruncated, got %v", v.Err())
+	}
+}
+
+// 定位到的值不会被整体扫描：后面的数据被截断时，前面的 key 仍然可以访问
+func TestViewLazy(t *testing.T) {
+	data := []byte{
+		byte(SFS_OBJECT), 0, 1,
+		0, 1, 'p', byte(SFS_OBJECT), 0, 2,
+		0, 1, 'c', byte(UTF_STRING), 0, 1, 'x',
+		0, 4, 'r', 'o', 'w', 's', byte(SFS_ARRAY), 0x03, 0xe8, // 1000 个元素，数据已截断
+	}
+	view, err := ViewOf(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := view.Get("p").Get("c").String(); got != "x" {
+		t.Fatalf("p.c = %q", got)
+	}
+	rows := view.Get("p").Get("rows")
+	if !rows.Exists() || rows.Len() != 1000 {
+		t.Fatalf("p.rows = %+v, Len %d", rows, rows.Len())
+	}
+
+	// 需要跳过损坏的值时才报告错误
+	var de *DecodeError
+	if err := rows.Index(1).Err(); !errors.As(err, &de) {
+		t.Fatalf("expected DecodeError for p.rows[1], got %v", err)
+	}
+	if err := view.Get("p").Get("missing").Err(); !errors.As(err, &de) {
+		t.Fatalf("expected DecodeError for p.missing, got %v", err)
+	}
+	if _, err := rows.Value(); err == nil {
+		t.Fatal("expected error decoding truncated p.rows")
+	}
+
+	// Value 报告的偏移与 Unpacker 解码同样的字节时相同
+	bad := []byte{
+		byte(SFS_OBJECT), 0, 1,
+		0, 1, 'p', byte(SFS_ARRAY), 0, 2,
+		byte(INT), 0, 0, 0, 1,
+		byte(UTF_STRING), 0, 1, 0xff,
+	}
+	opts := UnpackOptions{InvalidUTF8: InvalidUTF8Reject}
+	for name, data := range map[string][]byte{"invalid": bad, "truncated": bad[:len(bad)-1]} {
+		_, want := (&Unpacker{data: data, opts: opts}).decodeValue()
+		var wantDE *DecodeError
+		if !errors.As(want, &wantDE) {
+			t.Fatalf("%s: expected DecodeError from Unpacker, got %v", name, want)
+		}
+		view, _ := ViewOf(data)
+		_, err := view.WithOptions(opts).Get("p").Value()
+		if !errors.As(err, &de) || de.Offset != wantDE.Offset || !errors.Is(err, wantDE.Err) {
+			t.Errorf("%s: Value error %v, Unpacker error %v", name, err, want)
+		}
+	}
+}